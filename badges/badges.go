@@ -0,0 +1,260 @@
+package badges
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// rule kinds, every kind reads Threshold except RuleCountrySet that reads Countries
+const (
+	// RuleCountries is earned after visiting Threshold different countries
+	RuleCountries = "countries"
+	// RuleCities is earned after visiting Threshold different cities
+	RuleCities = "cities"
+	// RuleContinents is earned after visiting Threshold different continents
+	RuleContinents = "continents"
+	// RuleTrips is earned after Threshold trips
+	RuleTrips = "trips"
+	// RuleCountrySet is earned after visiting every country in Countries
+	RuleCountrySet = "countryset"
+	// RuleDaysInYear is earned after travelling Threshold days within a single calendar year
+	RuleDaysInYear = "daysinyear"
+)
+
+type (
+	// Definition is a badge and the rule a travel history must satisfy to earn it
+	Definition struct {
+		ID          string
+		Name        string
+		Description string
+		Rule        Rule
+	}
+
+	// Rule is the declarative condition of a badge
+	Rule struct {
+		Kind      string
+		Threshold int
+		Countries []string
+	}
+
+	// Stop is a place of the travel history, a trip or one of its legs, Country is the iso code of the country
+	Stop struct {
+		TripID    int
+		Country   string
+		Continent string
+		City      string
+		Start     time.Time
+		End       time.Time
+	}
+
+	// Earned is a badge of the history and the day it was earned
+	Earned struct {
+		ID   string
+		Date time.Time
+	}
+
+	// progress holds what was visited so far while the history is replayed
+	progress struct {
+		countries  map[string]struct{}
+		cities     map[string]struct{}
+		continents map[string]struct{}
+		trips      map[int]struct{}
+		days       map[time.Time]struct{}
+		yearDays   map[int]int
+	}
+)
+
+var (
+	errorDuplicateBadge = errors.New("badge id is used more than once")
+	errorInvalidRule    = errors.New("badge rule is not valid")
+)
+
+// Definitions are the badges that can be earned, ids are stored with the awarded badges so they must never change,
+// run the evaluatebadges command after adding a definition so existing histories are awarded it
+var Definitions = []Definition{
+	{ID: "trips-1", Name: "First steps", Description: "Went on a first trip", Rule: Rule{Kind: RuleTrips, Threshold: 1}},
+	{ID: "trips-10", Name: "Frequent traveller", Description: "Went on 10 trips", Rule: Rule{Kind: RuleTrips, Threshold: 10}},
+	{ID: "trips-50", Name: "Always packed", Description: "Went on 50 trips", Rule: Rule{Kind: RuleTrips, Threshold: 50}},
+	{ID: "countries-5", Name: "Explorer", Description: "Visited 5 countries", Rule: Rule{Kind: RuleCountries, Threshold: 5}},
+	{ID: "countries-10", Name: "Globetrotter", Description: "Visited 10 countries", Rule: Rule{Kind: RuleCountries, Threshold: 10}},
+	{ID: "countries-25", Name: "World citizen", Description: "Visited 25 countries", Rule: Rule{Kind: RuleCountries, Threshold: 25}},
+	{ID: "countries-50", Name: "Passport full", Description: "Visited 50 countries", Rule: Rule{Kind: RuleCountries, Threshold: 50}},
+	{ID: "cities-10", Name: "City hopper", Description: "Visited 10 cities", Rule: Rule{Kind: RuleCities, Threshold: 10}},
+	{ID: "cities-50", Name: "Urban explorer", Description: "Visited 50 cities", Rule: Rule{Kind: RuleCities, Threshold: 50}},
+	{ID: "continents-3", Name: "Intercontinental", Description: "Visited 3 continents", Rule: Rule{Kind: RuleContinents, Threshold: 3}},
+	{ID: "continents-6", Name: "Around the world", Description: "Visited 6 continents", Rule: Rule{Kind: RuleContinents, Threshold: 6}},
+	{ID: "scandinavia", Name: "Scandinavian", Description: "Visited all of Scandinavia", Rule: Rule{Kind: RuleCountrySet, Countries: []string{"DK", "NO", "SE"}}},
+	{ID: "nordics", Name: "Nordic", Description: "Visited every Nordic country", Rule: Rule{Kind: RuleCountrySet, Countries: []string{"DK", "FI", "IS", "NO", "SE"}}},
+	{ID: "benelux", Name: "Low countries", Description: "Visited all of Benelux", Rule: Rule{Kind: RuleCountrySet, Countries: []string{"BE", "LU", "NL"}}},
+	{ID: "iberia", Name: "Iberian", Description: "Visited all of the Iberian peninsula", Rule: Rule{Kind: RuleCountrySet, Countries: []string{"ES", "PT"}}},
+	{ID: "days-30", Name: "On the road", Description: "Travelled 30 days in a single year", Rule: Rule{Kind: RuleDaysInYear, Threshold: 30}},
+	{ID: "days-100", Name: "Nomad", Description: "Travelled 100 days in a single year", Rule: Rule{Kind: RuleDaysInYear, Threshold: 100}},
+}
+
+// Lookup returns the definition with the given id
+func Lookup(id string) (Definition, bool) {
+	for _, definition := range Definitions {
+		if definition.ID == id {
+			return definition, true
+		}
+	}
+
+	return Definition{}, false
+}
+
+// Validate checks that every definition has a unique id and a rule that can be earned
+func Validate(definitions []Definition) error {
+	ids := make(map[string]struct{}, len(definitions))
+
+	for _, definition := range definitions {
+		if _, ok := ids[definition.ID]; ok || definition.ID == "" {
+			return errorDuplicateBadge
+		}
+
+		ids[definition.ID] = struct{}{}
+
+		switch definition.Rule.Kind {
+		case RuleCountries, RuleCities, RuleContinents, RuleTrips, RuleDaysInYear:
+			if definition.Rule.Threshold <= 0 {
+				return errorInvalidRule
+			}
+		case RuleCountrySet:
+			if len(definition.Rule.Countries) == 0 {
+				return errorInvalidRule
+			}
+		default:
+			return errorInvalidRule
+		}
+	}
+
+	return nil
+}
+
+// Evaluate replays the stops that started on or before today in chronological order and returns the badges earned,
+// a badge is earned on the first day of the stop that completed its rule or, for RuleDaysInYear, on the day that did
+func Evaluate(stops []Stop, definitions []Definition, today time.Time) []Earned {
+	today = day(today)
+
+	started := make([]Stop, 0, len(stops))
+	for _, stop := range stops {
+		if day(stop.Start).After(today) {
+			continue
+		}
+
+		started = append(started, stop)
+	}
+
+	sort.SliceStable(started, func(i, j int) bool {
+		if started[i].Start.Equal(started[j].Start) {
+			return started[i].TripID < started[j].TripID
+		}
+
+		return started[i].Start.Before(started[j].Start)
+	})
+
+	state := progress{
+		countries:  make(map[string]struct{}),
+		cities:     make(map[string]struct{}),
+		continents: make(map[string]struct{}),
+		trips:      make(map[int]struct{}),
+		days:       make(map[time.Time]struct{}),
+		yearDays:   make(map[int]int),
+	}
+
+	earned := make(map[string]time.Time)
+
+	for _, stop := range started {
+		start := day(stop.Start)
+
+		end := day(stop.End)
+		if end.After(today) {
+			end = today
+		}
+
+		for current := start; !current.After(end); current = current.AddDate(0, 0, 1) {
+			if _, ok := state.days[current]; ok {
+				continue
+			}
+
+			state.days[current] = struct{}{}
+			state.yearDays[current.Year()]++
+
+			for _, definition := range definitions {
+				if _, ok := earned[definition.ID]; ok || definition.Rule.Kind != RuleDaysInYear {
+					continue
+				}
+
+				if state.yearDays[current.Year()] >= definition.Rule.Threshold {
+					earned[definition.ID] = current
+				}
+			}
+		}
+
+		state.trips[stop.TripID] = struct{}{}
+		state.countries[strings.ToUpper(stop.Country)] = struct{}{}
+
+		if stop.Continent != "" {
+			state.continents[stop.Continent] = struct{}{}
+		}
+
+		if city := strings.ToLower(strings.TrimSpace(stop.City)); city != "" {
+			state.cities[city] = struct{}{}
+		}
+
+		for _, definition := range definitions {
+			if _, ok := earned[definition.ID]; ok {
+				continue
+			}
+
+			if state.satisfies(definition.Rule) {
+				earned[definition.ID] = start
+			}
+		}
+	}
+
+	badges := make([]Earned, 0, len(earned))
+	for id, date := range earned {
+		badges = append(badges, Earned{ID: id, Date: date})
+	}
+
+	sort.Slice(badges, func(i, j int) bool {
+		if badges[i].Date.Equal(badges[j].Date) {
+			return badges[i].ID < badges[j].ID
+		}
+
+		return badges[i].Date.Before(badges[j].Date)
+	})
+
+	return badges
+}
+
+// satisfies checks the rules that only depend on what was visited, RuleDaysInYear is checked day by day
+func (state progress) satisfies(rule Rule) bool {
+	switch rule.Kind {
+	case RuleCountries:
+		return len(state.countries) >= rule.Threshold
+	case RuleCities:
+		return len(state.cities) >= rule.Threshold
+	case RuleContinents:
+		return len(state.continents) >= rule.Threshold
+	case RuleTrips:
+		return len(state.trips) >= rule.Threshold
+	case RuleCountrySet:
+		for _, country := range rule.Countries {
+			if _, ok := state.countries[strings.ToUpper(country)]; !ok {
+				return false
+			}
+		}
+
+		return len(rule.Countries) > 0
+	}
+
+	return false
+}
+
+// day drops the time of the date so days can be compared and used as keys
+func day(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+}
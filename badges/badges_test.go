@@ -0,0 +1,88 @@
+package badges
+
+import (
+	"testing"
+	"time"
+)
+
+func date(value string) time.Time {
+	parsed, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		panic(err)
+	}
+
+	return parsed
+}
+
+func TestValidate_Definitions(t *testing.T) {
+	err := Validate(Definitions)
+	if err != nil {
+		t.Fatalf("Expected the badge definitions to be valid, got %v", err)
+	}
+
+	err = Validate([]Definition{{ID: "a", Rule: Rule{Kind: RuleTrips, Threshold: 1}}, {ID: "a", Rule: Rule{Kind: RuleTrips, Threshold: 2}}})
+	if err == nil {
+		t.Error("Expected an error for repeated ids")
+	}
+
+	err = Validate([]Definition{{ID: "a", Rule: Rule{Kind: "unknown", Threshold: 1}}})
+	if err == nil {
+		t.Error("Expected an error for an unknown rule kind")
+	}
+}
+
+func TestEvaluate_CountriesAndSets(t *testing.T) {
+	definitions := []Definition{
+		{ID: "countries-2", Rule: Rule{Kind: RuleCountries, Threshold: 2}},
+		{ID: "scandinavia", Rule: Rule{Kind: RuleCountrySet, Countries: []string{"DK", "NO", "SE"}}},
+		{ID: "cities-2", Rule: Rule{Kind: RuleCities, Threshold: 2}},
+	}
+
+	stops := []Stop{
+		{TripID: 2, Country: "no", City: "Oslo", Start: date("2024-06-10"), End: date("2024-06-12")},
+		{TripID: 1, Country: "DK", City: "copenhagen", Start: date("2024-01-05"), End: date("2024-01-07")},
+		{TripID: 3, Country: "SE", City: "Stockholm", Start: date("2025-03-01"), End: date("2025-03-03")},
+		{TripID: 4, Country: "FI", Start: date("2030-01-01"), End: date("2030-01-03")},
+	}
+
+	earned := Evaluate(stops, definitions, date("2025-12-31"))
+
+	expected := []Earned{
+		{ID: "cities-2", Date: date("2024-06-10")},
+		{ID: "countries-2", Date: date("2024-06-10")},
+		{ID: "scandinavia", Date: date("2025-03-01")},
+	}
+
+	if len(earned) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, earned)
+	}
+
+	for i := range expected {
+		if earned[i].ID != expected[i].ID || !earned[i].Date.Equal(expected[i].Date) {
+			t.Errorf("Expected %v, got %v", expected[i], earned[i])
+		}
+	}
+}
+
+func TestEvaluate_DaysInYear(t *testing.T) {
+	definitions := []Definition{
+		{ID: "days-10", Rule: Rule{Kind: RuleDaysInYear, Threshold: 10}},
+	}
+
+	// the leg overlaps the trip so its days must not be counted twice
+	stops := []Stop{
+		{TripID: 1, Country: "PT", Start: date("2024-12-25"), End: date("2025-01-05")},
+		{TripID: 1, Country: "PT", City: "Porto", Start: date("2024-12-28"), End: date("2024-12-30")},
+		{TripID: 2, Country: "ES", Start: date("2025-02-01"), End: date("2025-02-10")},
+	}
+
+	earned := Evaluate(stops, definitions, date("2025-02-20"))
+	if len(earned) != 1 || !earned[0].Date.Equal(date("2025-02-05")) {
+		t.Fatalf("Expected the badge to be earned on the 10th day of 2025, got %v", earned)
+	}
+
+	earned = Evaluate(stops, definitions, date("2025-02-04"))
+	if len(earned) != 0 {
+		t.Errorf("Expected days after today not to be counted, got %v", earned)
+	}
+}
@@ -58,3 +58,14 @@ func (c *Cache) Flush() {
 	defer c.mu.Unlock()
 	c.store = make(map[string]Entry)
 }
+
+// DeleteFunc removes every entry that matches
+func (c *Cache) DeleteFunc(match func(key string, value interface{}) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, entry := range c.store {
+		if match(key, entry.Value) {
+			delete(c.store, key)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"memtravel/badges"
+	"memtravel/db"
+	"memtravel/handlers"
+)
+
+// evaluatebadges evaluates the badge definitions against the trips of every active user, it must be run after
+// a definition is added or changed and is meant to run daily so trips that started since their last change are counted
+func main() {
+	userID := flag.Int("user", 0, "only evaluate the badges of this user")
+	flag.Parse()
+
+	err := badges.Validate(badges.Definitions)
+	if err != nil {
+		log.Fatalf("invalid badge definitions: %s", err)
+	}
+
+	database, err := db.Connect()
+	if err != nil {
+		log.Fatalf("could not connect to database: %s", err)
+	}
+
+	defer database.Close()
+
+	userIDs := []int{*userID}
+
+	if *userID == 0 {
+		userIDs, err = activeUserIDs(database)
+		if err != nil {
+			log.Fatalf("could not read users: %s", err)
+		}
+	}
+
+	handler := handlers.NewHandler(database, nil, nil, nil, nil)
+	today := time.Now()
+	failed := 0
+
+	for _, id := range userIDs {
+		err = handler.RefreshBadges(id, today)
+		if err != nil {
+			log.Printf("could not evaluate badges of user %d: %s", id, err)
+			failed++
+		}
+	}
+
+	log.Printf("evaluated badges of %d users, %d failed", len(userIDs), failed)
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func activeUserIDs(database db.Database) ([]int, error) {
+	rows, err := database.Query(db.GetActiveUserIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	userIDs := []int{}
+
+	for rows.Next() {
+		var userID int
+
+		err = rows.Scan(&userID)
+		if err != nil {
+			return nil, err
+		}
+
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
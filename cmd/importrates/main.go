@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"memtravel/currency"
+	"memtravel/db"
+)
+
+// importrates loads daily exchange rates from a csv or json file into the database,
+// every rate must be quoted against currency.Base
+func main() {
+	file := flag.String("file", "", "path to the csv or json file with the exchange rates")
+	format := flag.String("format", "", "file format, csv or json, defaults to the file extension")
+	flag.Parse()
+
+	if *file == "" {
+		log.Fatal("missing -file")
+	}
+
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*file)), ".")
+	}
+
+	imported, err := run(*file, *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("imported %d exchange rates", imported)
+}
+
+// run imports the rates of the file and returns how many were stored, errors are returned
+// instead of exiting so the file and the database connection are always closed
+func run(file string, format string) (int, error) {
+	input, err := os.Open(file)
+	if err != nil {
+		return 0, fmt.Errorf("could not open rates file: %w", err)
+	}
+
+	defer input.Close()
+
+	rates, err := parseRates(input, format)
+	if err != nil {
+		return 0, fmt.Errorf("could not read rates: %w", err)
+	}
+
+	// make sure the rates are usable before touching the database
+	_, err = currency.NewTable(rates)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rates: %w", err)
+	}
+
+	database, err := db.Connect()
+	if err != nil {
+		return 0, fmt.Errorf("could not connect to database: %w", err)
+	}
+
+	defer database.Close()
+
+	transactions := make([]db.Transaction, len(rates))
+	for i, rate := range rates {
+		transactions[i] = db.Transaction{
+			Query:  db.UpsertExchangeRate,
+			Params: []any{rate.Day, rate.Currency, rate.Value},
+		}
+	}
+
+	err = database.ExecTransaction(transactions)
+	if err != nil {
+		return 0, fmt.Errorf("could not import rates: %w", err)
+	}
+
+	return len(rates), nil
+}
+
+func parseRates(input io.Reader, format string) ([]currency.Rate, error) {
+	switch format {
+	case "csv":
+		return currency.ParseCSV(input)
+	case "json":
+		return currency.ParseJSON(input)
+	}
+
+	return nil, fmt.Errorf("unsupported format %q, use csv or json", format)
+}
@@ -22,6 +22,11 @@ type Config struct {
 	SMTPHost      string
 	SMTPPort      string
 	RandomCreator []byte
+	MediaRoot     string
+	MediaSecret   []byte
+	Boundaries    string
+	Emissions     string
+	BaseURL       string
 }
 
 // Envs holds the .env values
@@ -46,6 +51,11 @@ func initConfig() Config {
 		SMTPHost:      os.Getenv("SMTP_HOST"),
 		SMTPPort:      os.Getenv("SMTP_PORT"),
 		RandomCreator: []byte(os.Getenv("RANDOM_CREATOR")),
+		MediaRoot:     os.Getenv("MEDIA_ROOT"),
+		MediaSecret:   []byte(os.Getenv("MEDIA_SECRET")),
+		Boundaries:    os.Getenv("COUNTRY_BOUNDARIES"),
+		Emissions:     os.Getenv("EMISSION_FACTORS"),
+		BaseURL:       strings.TrimSuffix(os.Getenv("BASE_URL"), "/"),
 	}
 }
 
@@ -0,0 +1,153 @@
+package currency
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+// Base is the currency every rate in a Table is quoted against
+const Base = "EUR"
+
+var (
+	errorInvalidCode = errors.New("invalid currency code")
+	errorInvalidRate = errors.New("exchange rates must be positive")
+	errorNoRate      = errors.New("no exchange rate available for the currency on the given day")
+	errorOverflow    = errors.New("converted amount is too big")
+)
+
+// exponents holds the currencies that do not use 2 decimal places for their minor unit
+var exponents = map[string]int{
+	"BHD": 3, "BIF": 0, "CLF": 4, "CLP": 0, "DJF": 0, "GNF": 0, "IQD": 3, "ISK": 0,
+	"JOD": 3, "JPY": 0, "KMF": 0, "KRW": 0, "KWD": 3, "LYD": 3, "OMR": 3, "PYG": 0,
+	"RWF": 0, "TND": 3, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0,
+	"XPF": 0,
+}
+
+// Rate is the amount of Currency that one unit of Base buys on Day
+type Rate struct {
+	Day      time.Time
+	Currency string
+	Value    float64
+}
+
+// Table holds the daily exchange rates of every currency sorted by day
+type Table struct {
+	rates map[string][]Rate
+}
+
+// Valid checks that the code looks like an ISO 4217 code, three upper case letters
+func Valid(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+
+	for _, char := range code {
+		if char < 'A' || char > 'Z' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Exponent returns the number of decimal places of the minor unit of the currency
+func Exponent(code string) int {
+	if exponent, ok := exponents[code]; ok {
+		return exponent
+	}
+
+	return 2
+}
+
+// NewTable creates a new table from the given rates, when a currency has more than one rate
+// for the same day the last one wins
+func NewTable(rates []Rate) (*Table, error) {
+	table := &Table{
+		rates: make(map[string][]Rate),
+	}
+
+	for _, rate := range rates {
+		if !Valid(rate.Currency) {
+			return nil, errorInvalidCode
+		}
+
+		if rate.Value <= 0 || math.IsInf(rate.Value, 0) || math.IsNaN(rate.Value) {
+			return nil, errorInvalidRate
+		}
+
+		rate.Day = truncateDay(rate.Day)
+		table.rates[rate.Currency] = append(table.rates[rate.Currency], rate)
+	}
+
+	for code, currencyRates := range table.rates {
+		sort.SliceStable(currencyRates, func(i, j int) bool { return currencyRates[i].Day.Before(currencyRates[j].Day) })
+
+		// keep only the last rate of each day
+		unique := currencyRates[:0]
+		for _, rate := range currencyRates {
+			if len(unique) > 0 && unique[len(unique)-1].Day.Equal(rate.Day) {
+				unique[len(unique)-1] = rate
+				continue
+			}
+
+			unique = append(unique, rate)
+		}
+
+		table.rates[code] = unique
+	}
+
+	return table, nil
+}
+
+// Rate returns the most recent rate of the currency published on or before the day
+func (table *Table) Rate(code string, day time.Time) (float64, error) {
+	if code == Base {
+		return 1, nil
+	}
+
+	currencyRates := table.rates[code]
+	day = truncateDay(day)
+
+	// index of the first rate after the day, the one before it is the rate we need
+	index := sort.Search(len(currencyRates), func(i int) bool { return currencyRates[i].Day.After(day) })
+	if index == 0 {
+		return 0, errorNoRate
+	}
+
+	return currencyRates[index-1].Value, nil
+}
+
+// Convert changes an amount in minor units of one currency into minor units of another
+// using the rates of the given day, the result is rounded half away from zero
+func (table *Table) Convert(amount int64, from string, to string, day time.Time) (int64, error) {
+	if from == to {
+		return amount, nil
+	}
+
+	fromRate, err := table.Rate(from, day)
+	if err != nil {
+		return 0, err
+	}
+
+	toRate, err := table.Rate(to, day)
+	if err != nil {
+		return 0, err
+	}
+
+	major := float64(amount) / math.Pow10(Exponent(from))
+	converted := math.Round(major / fromRate * toRate * math.Pow10(Exponent(to)))
+
+	if math.Abs(converted) > math.MaxInt64/2 {
+		return 0, errorOverflow
+	}
+
+	return int64(converted), nil
+}
+
+// truncateDay drops the time of day so rates are always compared by date only
+func truncateDay(day time.Time) time.Time {
+	year, month, date := day.Date()
+	return time.Date(year, month, date, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,119 @@
+package currency
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func day(date string) time.Time {
+	parsed, _ := time.Parse(time.DateOnly, date)
+	return parsed
+}
+
+func TestTable_RateUsesLatestDayOnOrBefore(t *testing.T) {
+	table, err := NewTable([]Rate{
+		{Day: day("2024-01-03"), Currency: "USD", Value: 1.2},
+		{Day: day("2024-01-01"), Currency: "USD", Value: 1.1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rate, err := table.Rate("USD", day("2024-01-02"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rate != 1.1 {
+		t.Errorf("Expected rate 1.1, got %v", rate)
+	}
+
+	rate, err = table.Rate("USD", day("2024-02-01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rate != 1.2 {
+		t.Errorf("Expected rate 1.2, got %v", rate)
+	}
+
+	_, err = table.Rate("USD", day("2023-12-31"))
+	if err == nil {
+		t.Error("Expected an error for a day before the first rate")
+	}
+}
+
+func TestTable_ConvertHandlesMinorUnits(t *testing.T) {
+	table, err := NewTable([]Rate{
+		{Day: day("2024-01-01"), Currency: "USD", Value: 1.1},
+		{Day: day("2024-01-01"), Currency: "JPY", Value: 160},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 10.00 EUR is 11.00 USD
+	converted, err := table.Convert(1000, Base, "USD", day("2024-01-05"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if converted != 1100 {
+		t.Errorf("Expected 1100, got %d", converted)
+	}
+
+	// 11.00 USD is 1600 JPY which has no decimal places
+	converted, err = table.Convert(1100, "USD", "JPY", day("2024-01-05"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if converted != 1600 {
+		t.Errorf("Expected 1600, got %d", converted)
+	}
+}
+
+func TestNewTable_RejectsInvalidRates(t *testing.T) {
+	_, err := NewTable([]Rate{{Day: day("2024-01-01"), Currency: "USD", Value: 0}})
+	if err == nil {
+		t.Error("Expected an error for a zero rate")
+	}
+
+	_, err = NewTable([]Rate{{Day: day("2024-01-01"), Currency: "usd", Value: 1}})
+	if err == nil {
+		t.Error("Expected an error for an invalid currency code")
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	rates, err := ParseCSV(strings.NewReader("date,currency,rate\n2024-01-01,usd,1.1\n2024-01-01, GBP, 0.86\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rates) != 2 || rates[0].Currency != "USD" || rates[1].Value != 0.86 {
+		t.Errorf("Unexpected rates %v", rates)
+	}
+
+	_, err = ParseCSV(strings.NewReader("2024-01-01,USD\n"))
+	if err == nil {
+		t.Error("Expected an error for a record with missing columns")
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	rates, err := ParseJSON(strings.NewReader(`[{"date": "2024-01-01", "rates": {"USD": 1.1}}, {"date": "2024-01-02", "rates": {"USD": 1.2}}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rates) != 2 || !rates[1].Day.Equal(day("2024-01-02")) {
+		t.Errorf("Unexpected rates %v", rates)
+	}
+
+	_, err = ParseJSON(strings.NewReader(`[{"date": "01/01/2024", "rates": {"USD": 1.1}}]`))
+	if err == nil {
+		t.Error("Expected an error for an invalid date")
+	}
+}
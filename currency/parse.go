@@ -0,0 +1,106 @@
+package currency
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jsonDay is the blueprint for a single day of rates in the json files
+type jsonDay struct {
+	Date  string             `json:"date"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+var (
+	errorInvalidCSVRecord = errors.New("csv records must have the date, currency and rate columns")
+)
+
+// ParseCSV reads rates from a csv file with the columns date (YYYY-MM-DD), currency and rate,
+// a first line starting with "date" is treated as a header and skipped
+func ParseCSV(reader io.Reader) ([]Rate, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+	csvReader.Comment = '#'
+
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) > 0 && strings.EqualFold(records[0][0], "date") {
+		records = records[1:]
+	}
+
+	rates := make([]Rate, 0, len(records))
+
+	for line, record := range records {
+		if len(record) != 3 {
+			return nil, errorInvalidCSVRecord
+		}
+
+		value, err := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", line+1, err)
+		}
+
+		rate, err := newRate(record[0], record[1], value)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", line+1, err)
+		}
+
+		rates = append(rates, rate)
+	}
+
+	return rates, nil
+}
+
+// ParseJSON reads rates from a json file holding a list of days, each with its date (YYYY-MM-DD)
+// and the rates of every currency, e.g. [{"date": "2024-01-02", "rates": {"USD": 1.09}}]
+func ParseJSON(reader io.Reader) ([]Rate, error) {
+	var days []jsonDay
+
+	err := json.NewDecoder(reader).Decode(&days)
+	if err != nil {
+		return nil, err
+	}
+
+	var rates []Rate
+
+	for _, day := range days {
+		for code, value := range day.Rates {
+			rate, err := newRate(day.Date, code, value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", day.Date, err)
+			}
+
+			rates = append(rates, rate)
+		}
+	}
+
+	return rates, nil
+}
+
+// newRate validates the values read from a file and creates a new rate
+func newRate(date string, code string, value float64) (Rate, error) {
+	day, err := time.Parse(time.DateOnly, strings.TrimSpace(date))
+	if err != nil {
+		return Rate{}, err
+	}
+
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if !Valid(code) {
+		return Rate{}, errorInvalidCode
+	}
+
+	if value <= 0 {
+		return Rate{}, errorInvalidRate
+	}
+
+	return Rate{Day: day, Currency: code, Value: value}, nil
+}
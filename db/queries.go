@@ -2,8 +2,20 @@ package db
 
 const (
 	// User data
-	UpdateUserCountry = "UPDATE users SET country=$1 WHERE userid=$2"
-	SearchUser        = "SELECT fullname, username, profilepic FROM users WHERE active = true AND userid != $1 AND username ILIKE '%' || $2 || '%' ORDER BY username LIMIT 20 OFFSET $3"
+	UpdateUserCountry        = "UPDATE users SET country=$1 WHERE userid=$2"
+	GetUserProfile           = "SELECT u.userid, u.username, u.fullname, u.profilepic, COALESCE(u.country, 0), COALESCE(u.bio, ''), f.private, u.dob, f.showdob, (SELECT COUNT(*) FROM friends WHERE userone = u.userid OR usertwo = u.userid) FROM users u JOIN userflags f ON f.userid = u.userid WHERE u.userid=$1 AND u.active = true"
+	GetUserProfileByUsername = "SELECT u.userid, u.username, u.fullname, u.profilepic, COALESCE(u.country, 0), COALESCE(u.bio, ''), f.private, u.dob, f.showdob, (SELECT COUNT(*) FROM friends WHERE userone = u.userid OR usertwo = u.userid) FROM users u JOIN userflags f ON f.userid = u.userid WHERE lower(u.username) = lower($1) AND u.active = true"
+	GetUsername              = "SELECT username FROM users WHERE userid=$1"
+	UpdateUserProfile        = "UPDATE users SET fullname=$1, bio=$2, country=$3, username=$4 WHERE userid=$5"
+	UpdateShowDoB            = "UPDATE userflags SET showdob=$1 WHERE userid=$2"
+	SearchUser               = "WITH myfriends AS (SELECT CASE WHEN userone = $1 THEN usertwo ELSE userone END AS userid FROM friends WHERE userone = $1 OR usertwo = $1), friendsoffriends AS (SELECT DISTINCT CASE WHEN fr.userone = m.userid THEN fr.usertwo ELSE fr.userone END AS userid FROM friends fr JOIN myfriends m ON fr.userone = m.userid OR fr.usertwo = m.userid) SELECT u.userid, u.fullname, u.username, u.profilepic, f.private, m.userid IS NOT NULL FROM users u JOIN userflags f ON f.userid = u.userid LEFT JOIN myfriends m ON m.userid = u.userid LEFT JOIN friendsoffriends ff ON ff.userid = u.userid CROSS JOIN LATERAL (SELECT word_similarity($2, u.username) AS username, CASE WHEN f.private AND m.userid IS NULL THEN 0 ELSE word_similarity($2, u.fullname) END AS fullname) s WHERE u.active = true AND u.userid != $1 AND (s.username >= $4 OR s.fullname >= $4 OR u.username ILIKE '%' || $2 || '%') ORDER BY GREATEST(s.username, s.fullname) + CASE WHEN m.userid IS NOT NULL THEN $5 WHEN ff.userid IS NOT NULL THEN $6 ELSE 0 END DESC, u.username LIMIT $7 OFFSET $3"
+
+	// Username history
+	AddUsernameHistory   = "INSERT INTO usernamehistory (userid, username) VALUES ($1, $2)"
+	CountUsernameChanges = "SELECT COUNT(*) FROM usernamehistory WHERE userid=$1 AND changed > $2"
+	CheckUsernameTaken   = "SELECT EXISTS (SELECT 1 FROM users WHERE lower(username) = lower($1) AND userid != $2) OR EXISTS (SELECT 1 FROM usernamehistory WHERE lower(username) = lower($1) AND userid != $2 AND changed > $3)"
+	GetFreeUsernames     = "SELECT c.username FROM unnest($1::text[]) WITH ORDINALITY AS c(username, position) WHERE NOT EXISTS (SELECT 1 FROM users WHERE lower(username) = lower(c.username)) AND NOT EXISTS (SELECT 1 FROM usernamehistory WHERE lower(username) = lower(c.username) AND changed > $2) ORDER BY c.position LIMIT $3"
+	GetRenamedUsername   = "SELECT u.username FROM usernamehistory h JOIN users u ON u.userid = h.userid WHERE lower(h.username) = lower($1) AND h.changed > $2 AND u.active = true ORDER BY h.changed DESC LIMIT 1"
 
 	// Create Account
 	AddNewUser        = "INSERT INTO users (email, password, fullname, dob, country, username) VALUES ($1, $2, $3, $4, $5, $6)"
@@ -26,6 +38,8 @@ const (
 	// User Status
 	UpdateUserActiveStatus  = "UPDATE users SET active=$1 WHERE userid=$2"
 	UpdateUserPrivacyStatus = "UPDATE userflags SET private=$1 WHERE userid=$2"
+	UpdateTripVisibility    = "UPDATE userflags SET tripvisibility=$1 WHERE userid=$2"
+	GetTripVisibility       = "SELECT tripvisibility FROM userflags WHERE userid=$1"
 	GetActivationCode       = "SELECT code, email FROM activation WHERE code=$1"
 	RemoveActivationCode    = "DELETE FROM activation WHERE code=$1 AND email=$2"
 	ActivateUser            = "UPDATE users SET active=true WHERE email=$1"
@@ -33,23 +47,185 @@ const (
 	// Friend Requests
 	AddFriendRequest         = "INSERT INTO friendsrequest (requesterid, requestedid) VALUES ($1, $2)"
 	CheckIfUserHasFriend     = "SELECT 1 FROM friends WHERE (userone=$1 AND usertwo=$2) OR (userone=$2 AND usertwo=$1)"
+	GetFriendsSince          = "SELECT created FROM friends WHERE (userone=$1 AND usertwo=$2) OR (userone=$2 AND usertwo=$1)"
 	RemoveFromFriendsRequest = "DELETE FROM friendsrequest WHERE requesterid=$1 AND requestedid=$2"
 	DeclineFriendRequest     = "DELETE FROM friendsrequest WHERE requesterid=$1 AND requestedid=$2"
 	RemoveFriendRequest      = "DELETE FROM friendsrequest WHERE requesterid=$1 AND requestedid=$2"
 	AddNewFriend             = "INSERT INTO friends (userone, usertwo) VALUES ($1, $2)"
 	RemoveFriend             = "DELETE FROM friends WHERE (userone=$1 AND usertwo=$2) OR (userone=$2 AND usertwo=$1)"
 
+	// Pending friend requests, newest first, the next page queries continue after the (created, userid) of the cursor
+	GetIncomingFriendRequests     = "SELECT u.userid, u.fullname, u.username, u.profilepic, r.created FROM friendsrequest r JOIN users u ON u.userid = r.requesterid WHERE r.requestedid=$1 AND u.active = true ORDER BY r.created DESC, u.userid DESC LIMIT $2"
+	GetIncomingFriendRequestsNext = "SELECT u.userid, u.fullname, u.username, u.profilepic, r.created FROM friendsrequest r JOIN users u ON u.userid = r.requesterid WHERE r.requestedid=$1 AND u.active = true AND (r.created, u.userid) < ($3, $4) ORDER BY r.created DESC, u.userid DESC LIMIT $2"
+	GetOutgoingFriendRequests     = "SELECT u.userid, u.fullname, u.username, u.profilepic, r.created FROM friendsrequest r JOIN users u ON u.userid = r.requestedid WHERE r.requesterid=$1 AND u.active = true ORDER BY r.created DESC, u.userid DESC LIMIT $2"
+	GetOutgoingFriendRequestsNext = "SELECT u.userid, u.fullname, u.username, u.profilepic, r.created FROM friendsrequest r JOIN users u ON u.userid = r.requestedid WHERE r.requesterid=$1 AND u.active = true AND (r.created, u.userid) < ($3, $4) ORDER BY r.created DESC, u.userid DESC LIMIT $2"
+	CountFriendRequests           = "SELECT COUNT(*) FILTER (WHERE r.requestedid=$1), COUNT(*) FILTER (WHERE r.requesterid=$1) FROM friendsrequest r JOIN users u ON u.userid = CASE WHEN r.requestedid=$1 THEN r.requesterid ELSE r.requestedid END WHERE (r.requestedid=$1 OR r.requesterid=$1) AND u.active = true"
+
 	// Friends
 	GetAllFriends = "SELECT u.userid, u.fullname, u.profilepic FROM friends f JOIN users u ON f.userone = u.userid OR f.usertwo = u.userid WHERE (f.userone = $1 OR f.usertwo = $1) AND u.userid != $1"
 
+	// Close friends, userid chose friendid as a close friend so friendid can see the close friends trips of userid
+	GetFriendship      = "SELECT EXISTS(SELECT 1 FROM closefriends WHERE userid=$2 AND friendid=$1) FROM friends WHERE (userone=$1 AND usertwo=$2) OR (userone=$2 AND usertwo=$1)"
+	GetCloseFriends    = "SELECT u.userid, u.fullname, u.profilepic FROM closefriends c JOIN users u ON u.userid = c.friendid WHERE c.userid=$1 ORDER BY u.fullname"
+	AddCloseFriend     = "INSERT INTO closefriends (userid, friendid) SELECT $1, $2 WHERE EXISTS(SELECT 1 FROM friends WHERE (userone=$1 AND usertwo=$2) OR (userone=$2 AND usertwo=$1)) ON CONFLICT (userid, friendid) DO NOTHING"
+	RemoveCloseFriend  = "DELETE FROM closefriends WHERE userid=$1 AND friendid=$2"
+	RemoveCloseFriends = "DELETE FROM closefriends WHERE (userid=$1 AND friendid=$2) OR (userid=$2 AND friendid=$1)"
+
 	// Pinned
-	TripBelongsToUser = "SELECT 1 FROM trips WHERE userid=$1 AND tripid=$1"
+	TripBelongsToUser = "SELECT 1 FROM trips WHERE userid=$1 AND tripid=$2"
 	RemovePinned      = "DELETE FROM pinned WHERE userid=$1 AND tripid=$2"
 	AddPinned         = "INSERT INTO pinned (userid, tripid) VALUES ($1, $2)"
+	GetPinnedTrips    = "SELECT t.tripid, t.cover, upper(c.iso), t.startdate FROM pinned p JOIN trips t ON t.tripid = p.tripid JOIN countries c ON c.id = t.country WHERE p.userid=$1 AND t.userid=$1 AND t.visibility = ANY($2) ORDER BY t.startdate DESC, t.tripid DESC"
 
 	// Trips
-	RemoveTrip = "DELETE FROM trips WHERE id=$1 AND userid=$2"
+	AddTrip    = "INSERT INTO trips (userid, title, country, cities, startdate, enddate, notes, visibility) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING tripid"
+	EditTrip   = "UPDATE trips SET title=$1, country=$2, cities=$3, startdate=$4, enddate=$5, notes=$6, visibility=$7 WHERE tripid=$8 AND userid=$9"
+	GetTrip    = "SELECT tripid, userid, title, country, cities, startdate, enddate, cover, notes, visibility FROM trips WHERE tripid=$1"
+	RemoveTrip = "DELETE FROM trips WHERE tripid=$1 AND userid=$2"
+
+	// Trip timelines, paginated with a (startdate, tripid) cursor
+	GetUpcomingTrips      = "SELECT tripid, userid, title, country, cities, startdate, enddate, cover, notes, visibility FROM trips WHERE userid=$1 AND visibility = ANY($4) AND enddate >= $2 ORDER BY startdate, tripid LIMIT $3"
+	GetUpcomingTripsAfter = "SELECT tripid, userid, title, country, cities, startdate, enddate, cover, notes, visibility FROM trips WHERE userid=$1 AND visibility = ANY($4) AND enddate >= $2 AND (startdate, tripid) > ($5::date, $6::int) ORDER BY startdate, tripid LIMIT $3"
+	GetPreviousTrips      = "SELECT tripid, userid, title, country, cities, startdate, enddate, cover, notes, visibility FROM trips WHERE userid=$1 AND visibility = ANY($4) AND enddate < $2 ORDER BY startdate DESC, tripid DESC LIMIT $3"
+	GetPreviousTripsAfter = "SELECT tripid, userid, title, country, cities, startdate, enddate, cover, notes, visibility FROM trips WHERE userid=$1 AND visibility = ANY($4) AND enddate < $2 AND (startdate, tripid) < ($5::date, $6::int) ORDER BY startdate DESC, tripid DESC LIMIT $3"
+
+	// Trip legs
+	// coordinates are the ones of the city of the leg, or of its country when the city is not known
+	GetTripLegs           = "SELECT l.legid, l.tripid, l.position, l.city, l.country, l.arrival, l.departure, l.transport, COALESCE(ci.latitude, c.latitude), COALESCE(ci.longitude, c.longitude) FROM triplegs l JOIN countries c ON c.id = l.country LEFT JOIN LATERAL (SELECT latitude, longitude FROM cities WHERE country = l.country AND lower(name) = lower(l.city) ORDER BY cityid LIMIT 1) ci ON true WHERE l.tripid=$1 ORDER BY l.position"
+	AddTripLeg            = "INSERT INTO triplegs (tripid, position, city, country, arrival, departure, transport) VALUES ($1, (SELECT COALESCE(MAX(position), 0) + 1 FROM triplegs WHERE tripid=$1), $2, $3, $4, $5, $6)"
+	RemoveTripLeg         = "DELETE FROM triplegs WHERE legid=$1 AND tripid=$2"
+	UpdateTripLegPosition = "UPDATE triplegs SET position=$1 WHERE legid=$2 AND tripid=$3"
+	RenumberTripLegs      = "UPDATE triplegs l SET position = o.rn FROM (SELECT legid, ROW_NUMBER() OVER (ORDER BY position) AS rn FROM triplegs WHERE tripid=$1) o WHERE l.legid = o.legid"
+	SyncTripWithLegs      = "UPDATE trips SET startdate = l.arrival, enddate = l.departure FROM (SELECT MIN(arrival) AS arrival, MAX(departure) AS departure FROM triplegs WHERE tripid=$1) l WHERE trips.tripid=$1 AND l.arrival IS NOT NULL"
+
+	// Trip statistics, only trips and legs that already started and are visible to the viewer, $2, are counted
+	GetTripStats             = "SELECT COUNT(*), COALESCE(SUM(LEAST(enddate, CURRENT_DATE) - startdate + 1), 0) FROM trips WHERE userid=$1 AND visibility = ANY($2) AND startdate <= CURRENT_DATE"
+	GetTripCountriesCount    = "SELECT COUNT(DISTINCT country) FROM (SELECT country FROM trips WHERE userid=$1 AND visibility = ANY($2) AND startdate <= CURRENT_DATE UNION SELECT l.country FROM triplegs l JOIN trips t ON t.tripid = l.tripid WHERE t.userid=$1 AND t.visibility = ANY($2) AND l.arrival <= CURRENT_DATE) v"
+	GetTripCitiesCount       = "SELECT COUNT(DISTINCT lower(city)) FROM (SELECT unnest(cities) AS city FROM trips WHERE userid=$1 AND visibility = ANY($2) AND startdate <= CURRENT_DATE UNION ALL SELECT l.city FROM triplegs l JOIN trips t ON t.tripid = l.tripid WHERE t.userid=$1 AND t.visibility = ANY($2) AND l.arrival <= CURRENT_DATE AND l.city <> '') v"
+	GetTripStatsPerYear      = "SELECT y.year, y.trips, y.days, COUNT(DISTINCT v.country) FROM (SELECT EXTRACT(YEAR FROM startdate)::int AS year, COUNT(*) AS trips, SUM(LEAST(enddate, CURRENT_DATE) - startdate + 1) AS days FROM trips WHERE userid=$1 AND visibility = ANY($2) AND startdate <= CURRENT_DATE GROUP BY 1) y LEFT JOIN (SELECT EXTRACT(YEAR FROM startdate)::int AS year, country FROM trips WHERE userid=$1 AND visibility = ANY($2) AND startdate <= CURRENT_DATE UNION SELECT EXTRACT(YEAR FROM l.arrival)::int, l.country FROM triplegs l JOIN trips t ON t.tripid = l.tripid WHERE t.userid=$1 AND t.visibility = ANY($2) AND l.arrival <= CURRENT_DATE) v ON v.year = y.year GROUP BY y.year, y.trips, y.days ORDER BY y.year"
+	GetTripFootprints        = "SELECT EXTRACT(YEAR FROM t.startdate)::int, l.tripid, l.transport, COALESCE(ci.latitude, c.latitude), COALESCE(ci.longitude, c.longitude) FROM triplegs l JOIN trips t ON t.tripid = l.tripid JOIN countries c ON c.id = l.country LEFT JOIN LATERAL (SELECT latitude, longitude FROM cities WHERE country = l.country AND lower(name) = lower(l.city) ORDER BY cityid LIMIT 1) ci ON true WHERE t.userid=$1 AND t.visibility = ANY($2) AND t.startdate <= CURRENT_DATE ORDER BY l.tripid, l.position"
+	GetTripStatsPerContinent = "SELECT c.continent, COUNT(DISTINCT c.id) FROM (SELECT country FROM trips WHERE userid=$1 AND visibility = ANY($2) AND startdate <= CURRENT_DATE UNION SELECT l.country FROM triplegs l JOIN trips t ON t.tripid = l.tripid WHERE t.userid=$1 AND t.visibility = ANY($2) AND l.arrival <= CURRENT_DATE) v JOIN countries c ON c.id = v.country GROUP BY c.continent ORDER BY c.continent"
+
+	// Media
+	AddTripMedia             = "INSERT INTO tripmedia (tripid, key, contenttype) VALUES ($1, $2, $3) RETURNING mediaid, created"
+	GetTripMedia             = "SELECT mediaid, tripid, key, contenttype, created FROM tripmedia WHERE tripid=$1 ORDER BY created, mediaid"
+	GetTripMediaKey          = "SELECT key FROM tripmedia WHERE mediaid=$1 AND tripid=$2"
+	RemoveTripMedia          = "DELETE FROM tripmedia WHERE mediaid=$1 AND tripid=$2"
+	CountTripMedia           = "SELECT COUNT(*) FROM tripmedia WHERE tripid=$1 AND mediaid = ANY($2)"
+	GetTripCover             = "SELECT cover FROM trips WHERE tripid=$1"
+	GetTripMediaKeys         = "SELECT key FROM tripmedia WHERE tripid=$1 UNION SELECT cover FROM trips WHERE tripid=$1 AND cover != ''"
+	UpdateTripCover          = "UPDATE trips SET cover=$1 WHERE tripid=$2 AND userid=$3"
+	GetUserProfilePicture    = "SELECT profilepic FROM users WHERE userid=$1"
+	UpdateUserProfilePicture = "UPDATE users SET profilepic=$1 WHERE userid=$2"
+
+	// Journal
+	GetJournalEntries        = "SELECT entryid, tripid, day, text, photos, latitude, longitude, placename FROM journal WHERE tripid=$1 ORDER BY day, entryid"
+	AddJournalEntry          = "INSERT INTO journal (tripid, day, text, photos, latitude, longitude, placename) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING entryid"
+	EditJournalEntry         = "UPDATE journal SET day=$1, text=$2, photos=$3, latitude=$4, longitude=$5, placename=$6, updated=NOW() WHERE entryid=$7 AND tripid=$8"
+	RemoveJournalEntry       = "DELETE FROM journal WHERE entryid=$1 AND tripid=$2"
+	RemoveJournalEntryPhotos = "UPDATE journal SET photos = array_remove(photos, $1) WHERE tripid=$2"
+
+	// Ratings, country and trip ids are 0 and city is empty when they are not part of the target
+	HasVisitedCountry     = "SELECT EXISTS(SELECT 1 FROM trips WHERE userid=$1 AND country=$2 AND startdate <= CURRENT_DATE UNION ALL SELECT 1 FROM triplegs l JOIN trips t ON t.tripid = l.tripid WHERE t.userid=$1 AND l.country=$2 AND l.arrival <= CURRENT_DATE)"
+	HasVisitedCity        = "SELECT EXISTS(SELECT 1 FROM trips t CROSS JOIN LATERAL unnest(t.cities) AS city WHERE t.userid=$1 AND t.country=$2 AND t.startdate <= CURRENT_DATE AND lower(city)=$3 UNION ALL SELECT 1 FROM triplegs l JOIN trips t ON t.tripid = l.tripid WHERE t.userid=$1 AND l.country=$2 AND l.arrival <= CURRENT_DATE AND lower(l.city)=$3)"
+	HasTakenTrip          = "SELECT EXISTS(SELECT 1 FROM trips t WHERE t.tripid=$2 AND t.startdate <= CURRENT_DATE AND (t.userid=$1 OR EXISTS(SELECT 1 FROM tripmembers m WHERE m.tripid = t.tripid AND m.userid=$1)))"
+	UpsertRating          = "INSERT INTO ratings (userid, target, country, city, tripid, stars, review) VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (userid, target, country, city, tripid) DO UPDATE SET stars=EXCLUDED.stars, review=EXCLUDED.review, updated=NOW() RETURNING ratingid"
+	GetCountryRatings     = "SELECT country, COUNT(*), AVG(stars), COUNT(*) FILTER (WHERE stars=1), COUNT(*) FILTER (WHERE stars=2), COUNT(*) FILTER (WHERE stars=3), COUNT(*) FILTER (WHERE stars=4), COUNT(*) FILTER (WHERE stars=5) FROM ratings WHERE target='country' GROUP BY country ORDER BY country"
+	GetCountryRating      = "SELECT country, COUNT(*), AVG(stars), COUNT(*) FILTER (WHERE stars=1), COUNT(*) FILTER (WHERE stars=2), COUNT(*) FILTER (WHERE stars=3), COUNT(*) FILTER (WHERE stars=4), COUNT(*) FILTER (WHERE stars=5) FROM ratings WHERE target='country' AND country=$1 GROUP BY country"
+	GetCountryCityRatings = "SELECT city, COUNT(*), AVG(stars), COUNT(*) FILTER (WHERE stars=1), COUNT(*) FILTER (WHERE stars=2), COUNT(*) FILTER (WHERE stars=3), COUNT(*) FILTER (WHERE stars=4), COUNT(*) FILTER (WHERE stars=5) FROM ratings WHERE target='city' AND country=$1 GROUP BY city ORDER BY city"
+
+	// Organise, group trip plans with their members, proposals and votes
+	AddPlan                = "WITH p AS (INSERT INTO plans (ownerid, title) VALUES ($1, $2) RETURNING planid) INSERT INTO planmembers (planid, userid, status) SELECT planid, $1, 'accepted' FROM p RETURNING planid"
+	GetPlan                = "SELECT planid, ownerid, title, status, destination, dateoption FROM plans WHERE planid=$1"
+	GetUserPlans           = "SELECT p.planid, p.ownerid, p.title, p.status, m.status FROM plans p JOIN planmembers m ON m.planid = p.planid WHERE m.userid=$1 AND m.status <> 'declined' ORDER BY p.created DESC"
+	GetPlanMembership      = "SELECT m.status, p.ownerid, p.status FROM planmembers m JOIN plans p ON p.planid = m.planid WHERE m.planid=$1 AND m.userid=$2"
+	GetPlanMembers         = "SELECT u.userid, u.fullname, u.profilepic, m.status FROM planmembers m JOIN users u ON u.userid = m.userid WHERE m.planid=$1 ORDER BY u.fullname"
+	InvitePlanMember       = "INSERT INTO planmembers (planid, userid, status) VALUES ($1, $2, 'invited') ON CONFLICT (planid, userid) DO NOTHING"
+	UpdatePlanMemberStatus = "UPDATE planmembers SET status=$1 WHERE planid=$2 AND userid=$3 AND status='invited'"
+	AddPlanDestination     = "INSERT INTO plandestinations (planid, country, city, proposedby) VALUES ($1, $2, $3, $4)"
+	AddPlanDate            = "INSERT INTO plandates (planid, startdate, enddate, proposedby) VALUES ($1, $2, $3, $4)"
+	GetPlanDestinations    = "SELECT d.destinationid, d.country, d.city, d.proposedby, COUNT(v.userid) FROM plandestinations d LEFT JOIN planvotes v ON v.planid = d.planid AND v.kind='destination' AND v.optionid = d.destinationid WHERE d.planid=$1 GROUP BY d.destinationid ORDER BY COUNT(v.userid) DESC, d.destinationid"
+	GetPlanDates           = "SELECT d.dateid, d.startdate, d.enddate, d.proposedby, COUNT(v.userid) FROM plandates d LEFT JOIN planvotes v ON v.planid = d.planid AND v.kind='date' AND v.optionid = d.dateid WHERE d.planid=$1 GROUP BY d.dateid ORDER BY COUNT(v.userid) DESC, d.dateid"
+	PlanDestinationExists  = "SELECT EXISTS(SELECT 1 FROM plandestinations WHERE destinationid=$1 AND planid=$2)"
+	PlanDateExists         = "SELECT EXISTS(SELECT 1 FROM plandates WHERE dateid=$1 AND planid=$2)"
+	GetUserPlanVotes       = "SELECT kind, optionid FROM planvotes WHERE planid=$1 AND userid=$2"
+	UpsertPlanVote         = "INSERT INTO planvotes (planid, userid, kind, optionid) VALUES ($1, $2, $3, $4) ON CONFLICT (planid, userid, kind) DO UPDATE SET optionid=EXCLUDED.optionid"
+	SettlePlan             = "UPDATE plans SET status='settled', destination=$1, dateoption=$2 WHERE planid=$3 AND ownerid=$4 AND status='open'"
+
+	// Trip members, friends that share a trip with its owner
+	GetTripMembers        = "SELECT u.userid, u.fullname, u.profilepic FROM tripmembers m JOIN users u ON u.userid = m.userid WHERE m.tripid=$1 ORDER BY u.fullname"
+	AddTripMember         = "INSERT INTO tripmembers (tripid, userid) VALUES ($1, $2)"
+	RemoveTripMember      = "DELETE FROM tripmembers WHERE tripid=$1 AND userid=$2"
+	IsTripParticipant     = "SELECT EXISTS(SELECT 1 FROM trips WHERE tripid=$1 AND userid=$2 UNION ALL SELECT 1 FROM tripmembers WHERE tripid=$1 AND userid=$2)"
+	CountTripParticipants = "SELECT COUNT(*) FROM (SELECT userid FROM trips WHERE tripid=$1 UNION SELECT userid FROM tripmembers WHERE tripid=$1) p WHERE userid = ANY($2)"
+
+	// Expenses, amounts are stored in minor units of their currency
+	AddExpense      = "WITH e AS (INSERT INTO expenses (tripid, payerid, amount, currency, description, splitmode, spentat) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING expenseid) INSERT INTO expenseshares (expenseid, userid, amount) SELECT e.expenseid, s.userid, s.amount FROM e, unnest($8::int[], $9::bigint[]) AS s(userid, amount) RETURNING expenseid"
+	GetTripExpenses = "SELECT e.expenseid, e.payerid, e.amount, e.currency, e.description, e.splitmode, e.spentat, s.userid, s.amount FROM expenses e JOIN expenseshares s ON s.expenseid = e.expenseid WHERE e.tripid=$1 ORDER BY e.spentat DESC, e.expenseid DESC, s.userid"
+	RemoveExpense   = "DELETE FROM expenses WHERE expenseid=$1 AND tripid=$2 AND (payerid=$3 OR EXISTS(SELECT 1 FROM trips WHERE tripid=$2 AND userid=$3))"
+
+	// Checklists, items of a trip are read in a single query and grouped by checklist
+	GetTripChecklists       = "SELECT checklistid, kind, title FROM checklists WHERE tripid=$1 ORDER BY checklistid"
+	GetTripChecklistItems   = "SELECT i.itemid, i.checklistid, i.text, i.checked, i.assigneeid FROM checklistitems i JOIN checklists c ON c.checklistid = i.checklistid WHERE c.tripid=$1 ORDER BY i.checklistid, i.position"
+	AddChecklist            = "WITH c AS (INSERT INTO checklists (tripid, kind, title) VALUES ($1, $2, $3) RETURNING checklistid), i AS (INSERT INTO checklistitems (checklistid, position, text) SELECT c.checklistid, items.position, items.text FROM c, unnest($4::text[]) WITH ORDINALITY AS items(text, position)) SELECT checklistid FROM c"
+	RemoveChecklist         = "DELETE FROM checklists WHERE checklistid=$1 AND tripid=$2"
+	AddChecklistItem        = "INSERT INTO checklistitems (checklistid, position, text, assigneeid) SELECT checklistid, (SELECT COALESCE(MAX(position), 0) + 1 FROM checklistitems WHERE checklistid=$1), $3, $4 FROM checklists WHERE checklistid=$1 AND tripid=$2"
+	RemoveChecklistItem     = "DELETE FROM checklistitems i USING checklists c WHERE c.checklistid = i.checklistid AND i.itemid=$1 AND c.checklistid=$2 AND c.tripid=$3"
+	CheckChecklistItem      = "UPDATE checklistitems i SET checked=$1 FROM checklists c WHERE c.checklistid = i.checklistid AND i.itemid=$2 AND c.checklistid=$3 AND c.tripid=$4"
+	AssignChecklistItem     = "UPDATE checklistitems i SET assigneeid=$1 FROM checklists c WHERE c.checklistid = i.checklistid AND i.itemid=$2 AND c.checklistid=$3 AND c.tripid=$4"
+	GetChecklistTemplates   = "SELECT templateid, kind, title, items FROM checklisttemplates WHERE userid=$1 ORDER BY title, templateid"
+	GetChecklistTemplate    = "SELECT kind, title, items FROM checklisttemplates WHERE templateid=$1 AND userid=$2"
+	AddChecklistTemplate    = "INSERT INTO checklisttemplates (userid, kind, title, items) VALUES ($1, $2, $3, $4) RETURNING templateid"
+	RemoveChecklistTemplate = "DELETE FROM checklisttemplates WHERE templateid=$1 AND userid=$2"
+
+	// Calendar, subscription tokens are stored hashed so a leaked database does not expose the feeds
+	GetCalendarTrips     = "SELECT tripid, title, cities, startdate, enddate, notes FROM trips WHERE userid=$1 ORDER BY startdate, tripid"
+	GetCalendarLegs      = "SELECT l.legid, l.tripid, t.title, l.city, l.arrival, l.departure FROM triplegs l JOIN trips t ON t.tripid = l.tripid WHERE t.userid=$1 ORDER BY l.arrival, l.position"
+	UpsertCalendarToken  = "INSERT INTO calendartokens (userid, tokenhash) VALUES ($1, $2) ON CONFLICT (userid) DO UPDATE SET tokenhash=EXCLUDED.tokenhash, created=NOW()"
+	GetCalendarTokenUser = "SELECT userid FROM calendartokens WHERE tokenhash=$1"
+	RemoveCalendarToken  = "DELETE FROM calendartokens WHERE userid=$1"
+
+	// Trip drafts created from imported tracks, legs are kept as json until the draft is confirmed
+	GetCountryIDsByISO = "SELECT id, upper(iso) FROM countries WHERE upper(iso) = ANY($1)"
+	AddTripDraft       = "INSERT INTO tripdrafts (userid, title, country, startdate, enddate, legs) VALUES ($1, $2, $3, $4, $5, $6) RETURNING draftid"
+	GetTripDrafts      = "SELECT draftid, title, country, startdate, enddate, legs FROM tripdrafts WHERE userid=$1 ORDER BY created DESC, draftid DESC"
+	RemoveTripDraft    = "DELETE FROM tripdrafts WHERE draftid=$1 AND userid=$2"
+	ConfirmTripDraft   = "WITH d AS (DELETE FROM tripdrafts WHERE draftid=$1 AND userid=$2 RETURNING *), t AS (INSERT INTO trips (userid, title, country, cities, startdate, enddate, notes, visibility) SELECT userid, COALESCE(NULLIF($3, ''), title), country, '{}', startdate, enddate, '', $4 FROM d RETURNING tripid), l AS (INSERT INTO triplegs (tripid, position, city, country, arrival, departure, transport) SELECT t.tripid, leg.position, leg.city, leg.country, leg.arrival, leg.departure, leg.transport FROM t, d, jsonb_to_recordset(d.legs) AS leg(position int, city text, country int, arrival date, departure date, transport text)) SELECT tripid FROM t"
+
+	// Travel map, visibility holds the trip visibility levels the viewer is allowed to see
+	GetUserPrivacy       = "SELECT f.private FROM userflags f JOIN users u ON u.userid = f.userid WHERE f.userid=$1 AND u.active = true"
+	GetMapTrips          = "SELECT t.tripid, t.title, upper(c.iso), t.startdate, t.enddate, t.cities FROM trips t JOIN countries c ON c.id = t.country WHERE t.userid=$1 AND t.visibility = ANY($2) ORDER BY t.startdate, t.tripid"
+	GetMapTripLocations  = "SELECT j.tripid, j.latitude, j.longitude FROM journal j JOIN trips t ON t.tripid = j.tripid WHERE t.userid=$1 AND t.visibility = ANY($2) AND j.latitude IS NOT NULL AND j.longitude IS NOT NULL ORDER BY j.day, j.entryid"
+	GetVisitedCountries  = "SELECT upper(c.iso), MIN(v.day), MAX(v.day), COUNT(DISTINCT v.tripid) FROM (SELECT tripid, country, startdate AS day FROM trips WHERE userid=$1 AND visibility = ANY($2) AND startdate <= CURRENT_DATE UNION ALL SELECT t.tripid, l.country, l.arrival FROM triplegs l JOIN trips t ON t.tripid = l.tripid WHERE t.userid=$1 AND t.visibility = ANY($2) AND l.arrival <= CURRENT_DATE) v JOIN countries c ON c.id = v.country GROUP BY upper(c.iso) ORDER BY 1"
+	GetWishlistCountries = "SELECT DISTINCT upper(c.iso) FROM bucketlist b JOIN countries c ON c.id = b.country WHERE b.userid=$1 AND b.city = '' AND NOT EXISTS(SELECT 1 FROM trips t WHERE t.userid=$1 AND t.visibility = ANY($2) AND t.country = b.country AND t.startdate <= CURRENT_DATE) AND NOT EXISTS(SELECT 1 FROM triplegs l JOIN trips t ON t.tripid = l.tripid WHERE t.userid=$1 AND t.visibility = ANY($2) AND l.country = b.country AND l.arrival <= CURRENT_DATE) ORDER BY 1"
+
+	// Bucket list, cities are stored in lower case and an empty city means the whole country
+	GetBucketList             = "SELECT b.entryid, b.country, b.city, b.priority, b.notes, b.position, (SELECT MIN(v.day) FROM (SELECT t.startdate AS day FROM trips t WHERE t.userid = b.userid AND t.visibility = ANY($2) AND t.country = b.country AND t.startdate <= CURRENT_DATE AND (b.city = '' OR EXISTS(SELECT 1 FROM unnest(t.cities) AS c(city) WHERE lower(c.city) = b.city)) UNION ALL SELECT l.arrival FROM triplegs l JOIN trips t ON t.tripid = l.tripid WHERE t.userid = b.userid AND t.visibility = ANY($2) AND l.country = b.country AND l.arrival <= CURRENT_DATE AND (b.city = '' OR lower(l.city) = b.city)) v) FROM bucketlist b WHERE b.userid=$1 ORDER BY b.position, b.entryid"
+	CountBucketEntries        = "SELECT COUNT(*) FROM bucketlist WHERE userid=$1"
+	AddBucketEntry            = "INSERT INTO bucketlist (userid, country, city, priority, notes, position) SELECT $1, $2, $3, $4, $5, COALESCE(MAX(position), 0) + 1 FROM bucketlist WHERE userid=$1 ON CONFLICT (userid, country, city) DO NOTHING RETURNING entryid"
+	RemoveBucketEntry         = "DELETE FROM bucketlist WHERE entryid=$1 AND userid=$2"
+	UpdateBucketEntryPosition = "UPDATE bucketlist SET position=$1 WHERE entryid=$2 AND userid=$3"
+
+	// Badges, badgeid is the id of a badges.Definition
+	GetBadgeStops        = "SELECT t.tripid, upper(c.iso), c.continent, COALESCE(u.city, ''), t.startdate, t.enddate FROM trips t JOIN countries c ON c.id = t.country LEFT JOIN LATERAL unnest(t.cities) AS u(city) ON true WHERE t.userid=$1 AND t.visibility = ANY($2) UNION ALL SELECT t.tripid, upper(c.iso), c.continent, l.city, l.arrival, l.departure FROM triplegs l JOIN trips t ON t.tripid = l.tripid JOIN countries c ON c.id = l.country WHERE t.userid=$1 AND t.visibility = ANY($2)"
+	RemoveUnearnedBadges = "DELETE FROM userbadges WHERE userid=$1 AND NOT (badgeid = ANY($2))"
+	AddEarnedBadges      = "INSERT INTO userbadges (userid, badgeid, earned) SELECT $1, b.badgeid, b.earned FROM unnest($2::text[], $3::date[]) AS b(badgeid, earned) ON CONFLICT (userid, badgeid) DO NOTHING"
+	GetUserBadges        = "SELECT badgeid, earned FROM userbadges WHERE userid=$1 ORDER BY earned, badgeid"
+	GetActiveUserIDs     = "SELECT userid FROM users WHERE active = true ORDER BY userid"
+
+	// Share links, tokens are stored hashed like the calendar tokens
+	GetTripShares   = "SELECT shareid, expires, created FROM tripshares WHERE tripid=$1 ORDER BY created DESC, shareid DESC"
+	AddTripShare    = "INSERT INTO tripshares (tripid, tokenhash, expires) VALUES ($1, $2, $3) RETURNING shareid, created"
+	RemoveTripShare = "DELETE FROM tripshares WHERE shareid=$1 AND tripid=$2"
+	GetSharedTrip   = "SELECT tripid FROM tripshares WHERE tokenhash=$1 AND (expires IS NULL OR expires > NOW())"
+	GetCountryNames = "SELECT id, name FROM countries WHERE id = ANY($1)"
+
+	// Exchange rates, one rate per currency and day quoted against currency.Base
+	UpsertExchangeRate = "INSERT INTO exchangerates (day, currency, rate) VALUES ($1, $2, $3) ON CONFLICT (day, currency) DO UPDATE SET rate=EXCLUDED.rate"
+	GetExchangeRates   = "SELECT r.day, r.currency, r.rate FROM exchangerates r WHERE r.currency = ANY($1) AND r.day <= $2 AND r.day >= COALESCE((SELECT MAX(p.day) FROM exchangerates p WHERE p.currency = r.currency AND p.day <= $3), $3) ORDER BY r.day"
+	GetUserCurrency    = "SELECT c.currency FROM users u JOIN countries c ON c.id = u.country WHERE u.userid=$1"
 
 	// Countries
 	GetAllCountries = "SELECT id, iso, %s FROM countries ORDER BY %s"
+	CountCountries  = "SELECT COUNT(*) FROM countries"
 )
@@ -0,0 +1,58 @@
+package emissions
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// Factors holds the grams of CO2 emitted per passenger and kilometre by each transport mode
+type Factors map[string]float64
+
+var (
+	errorInvalidFactor = errors.New("emission factors cannot be negative")
+)
+
+// Default are the factors used when no table is configured, they are averages per passenger
+// of a short haul flight, a national train, an average car with a single occupant, a coach and a foot ferry
+var Default = Factors{
+	"plane": 246,
+	"train": 35,
+	"car":   170,
+	"bus":   27,
+	"boat":  19,
+	"bike":  0,
+	"walk":  0,
+	"other": 0,
+}
+
+// Load reads a json object of transport mode to grams per kilometre, modes missing from it keep the Default factor
+func Load(reader io.Reader) (Factors, error) {
+	var loaded map[string]float64
+
+	err := json.NewDecoder(reader).Decode(&loaded)
+	if err != nil {
+		return nil, err
+	}
+
+	factors := make(Factors, len(Default)+len(loaded))
+	for mode, factor := range Default {
+		factors[mode] = factor
+	}
+
+	for mode, factor := range loaded {
+		if factor < 0 {
+			return nil, errorInvalidFactor
+		}
+
+		factors[mode] = factor
+	}
+
+	return factors, nil
+}
+
+// Estimate returns the kilograms of CO2 emitted travelling the kilometres with the transport mode,
+// unknown modes are not counted
+func (factors Factors) Estimate(mode string, kilometres float64) float64 {
+	return factors[mode] * kilometres / 1000
+}
@@ -0,0 +1,47 @@
+package emissions
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoad_KeepsDefaults(t *testing.T) {
+	factors, err := Load(strings.NewReader(`{"plane": 150, "ebike": 5}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if factors["plane"] != 150 || factors["ebike"] != 5 || factors["train"] != Default["train"] {
+		t.Errorf("Unexpected factors %v", factors)
+	}
+
+	if Default["plane"] != 246 {
+		t.Errorf("Expected the default factors not to change, got %v", Default["plane"])
+	}
+}
+
+func TestLoad_NegativeFactor(t *testing.T) {
+	_, err := Load(strings.NewReader(`{"car": -1}`))
+	if err == nil {
+		t.Error("Expected an error for a negative factor")
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		mode       string
+		kilometres float64
+		expected   float64
+	}{
+		{"plane", 1000, 246},
+		{"train", 200, 7},
+		{"walk", 10, 0},
+		{"teleport", 100, 0},
+	}
+
+	for _, test := range tests {
+		if estimate := Default.Estimate(test.mode, test.kilometres); estimate != test.expected {
+			t.Errorf("Expected %v kg for %v km by %s, got %v", test.expected, test.kilometres, test.mode, estimate)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package expenses
+
+import (
+	"errors"
+	"sort"
+)
+
+const (
+	// SplitEqual divides the amount equally between every participant
+	SplitEqual = "equal"
+	// SplitShares divides the amount proportionally to the share weight of each participant
+	SplitShares = "shares"
+	// SplitExact uses the exact amount sent for each participant
+	SplitExact = "exact"
+
+	// MaxAmount is the biggest amount in minor units accepted for a single expense
+	MaxAmount = 1_000_000_000_000
+	// MaxShareWeight is the biggest weight accepted in the shares split mode
+	MaxShareWeight = 1000
+)
+
+var (
+	errorInvalidAmount       = errors.New("expense amount must be positive and not bigger than the maximum amount")
+	errorInvalidSplitMode    = errors.New("invalid expense split mode")
+	errorNoParticipants      = errors.New("expense needs at least one participant")
+	errorDuplicatedUser      = errors.New("expense participants must be unique")
+	errorInvalidShare        = errors.New("invalid participant share")
+	errorExactAmountMismatch = errors.New("exact shares must add up to the expense amount")
+)
+
+// Share is the part of an expense that belongs to a single user, the meaning of Value depends on where it is used:
+// the weight or exact amount sent for a split, or the amount in minor units that the user owes once split
+type Share struct {
+	UserID int
+	Value  int64
+}
+
+// Transfer is a payment that settles part of the balances between two users
+type Transfer struct {
+	From   int
+	To     int
+	Amount int64
+}
+
+// Split divides the amount between the participants according to the split mode and returns how much each one owes.
+// Amounts that cannot be divided evenly are given one minor unit at a time so the owed amounts always add up to amount
+func Split(amount int64, mode string, participants []Share) ([]Share, error) {
+	if amount <= 0 || amount > MaxAmount {
+		return nil, errorInvalidAmount
+	}
+
+	if len(participants) == 0 {
+		return nil, errorNoParticipants
+	}
+
+	// sort a copy so the leftover units always go to the same users
+	sorted := make([]Share, len(participants))
+	copy(sorted, participants)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].UserID < sorted[j].UserID })
+
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].UserID == sorted[i-1].UserID {
+			return nil, errorDuplicatedUser
+		}
+	}
+
+	switch mode {
+	case SplitEqual:
+		weighted := make([]Share, len(sorted))
+		for i, participant := range sorted {
+			weighted[i] = Share{UserID: participant.UserID, Value: 1}
+		}
+
+		return splitByWeight(amount, weighted), nil
+	case SplitShares:
+		for _, participant := range sorted {
+			if participant.Value <= 0 || participant.Value > MaxShareWeight {
+				return nil, errorInvalidShare
+			}
+		}
+
+		return splitByWeight(amount, sorted), nil
+	case SplitExact:
+		var total int64
+		for _, participant := range sorted {
+			if participant.Value < 0 {
+				return nil, errorInvalidShare
+			}
+
+			// checked before adding so huge values cannot overflow the total back to the amount
+			if participant.Value > amount-total {
+				return nil, errorExactAmountMismatch
+			}
+
+			total += participant.Value
+		}
+
+		if total != amount {
+			return nil, errorExactAmountMismatch
+		}
+
+		return sorted, nil
+	}
+
+	return nil, errorInvalidSplitMode
+}
+
+// splitByWeight gives each participant the floor of its proportional part and hands the leftover units
+// to the participants with the biggest remainders, ties are won by the lowest user id
+func splitByWeight(amount int64, weighted []Share) []Share {
+	var totalWeight int64
+	for _, participant := range weighted {
+		totalWeight += participant.Value
+	}
+
+	owed := make([]Share, len(weighted))
+	remainders := make([]int64, len(weighted))
+
+	var assigned int64
+	for i, participant := range weighted {
+		part := amount * participant.Value
+		owed[i] = Share{UserID: participant.UserID, Value: part / totalWeight}
+		remainders[i] = part % totalWeight
+		assigned += owed[i].Value
+	}
+
+	order := make([]int, len(weighted))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool { return remainders[order[i]] > remainders[order[j]] })
+
+	for i := int64(0); i < amount-assigned; i++ {
+		owed[order[i]].Value++
+	}
+
+	return owed
+}
+
+// Settle returns the transfers that bring every balance back to zero, positive balances are owed money
+// and negative balances owe money. Each transfer fully settles either the biggest debtor or the biggest creditor,
+// so there are never more transfers than users with a balance minus one
+func Settle(balances map[int]int64) []Transfer {
+	var creditors, debtors []Share
+
+	for userID, balance := range balances {
+		switch {
+		case balance > 0:
+			creditors = append(creditors, Share{UserID: userID, Value: balance})
+		case balance < 0:
+			debtors = append(debtors, Share{UserID: userID, Value: -balance})
+		}
+	}
+
+	byAmount := func(shares []Share) func(i, j int) bool {
+		return func(i, j int) bool {
+			if shares[i].Value == shares[j].Value {
+				return shares[i].UserID < shares[j].UserID
+			}
+
+			return shares[i].Value > shares[j].Value
+		}
+	}
+
+	sort.Slice(creditors, byAmount(creditors))
+	sort.Slice(debtors, byAmount(debtors))
+
+	transfers := []Transfer{}
+
+	for c, d := 0, 0; c < len(creditors) && d < len(debtors); {
+		amount := min(creditors[c].Value, debtors[d].Value)
+
+		transfers = append(transfers, Transfer{
+			From:   debtors[d].UserID,
+			To:     creditors[c].UserID,
+			Amount: amount,
+		})
+
+		creditors[c].Value -= amount
+		debtors[d].Value -= amount
+
+		if creditors[c].Value == 0 {
+			c++
+		}
+
+		if debtors[d].Value == 0 {
+			d++
+		}
+	}
+
+	return transfers
+}
@@ -0,0 +1,114 @@
+package expenses
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSplit_EqualGivesLeftoverToLowestIDs(t *testing.T) {
+	owed, err := Split(1000, SplitEqual, []Share{{UserID: 3}, {UserID: 1}, {UserID: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Share{{UserID: 1, Value: 334}, {UserID: 2, Value: 333}, {UserID: 3, Value: 333}}
+	for i := range expected {
+		if owed[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, owed)
+			break
+		}
+	}
+}
+
+func TestSplit_SharesAddUpToAmount(t *testing.T) {
+	owed, err := Split(1001, SplitShares, []Share{{UserID: 1, Value: 2}, {UserID: 2, Value: 1}, {UserID: 3, Value: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var total int64
+	for _, share := range owed {
+		total += share.Value
+	}
+
+	if total != 1001 {
+		t.Errorf("Expected owed amounts to add up to 1001, got %d", total)
+	}
+
+	if owed[0].Value != 501 {
+		t.Errorf("Expected user with double weight to owe 501, got %d", owed[0].Value)
+	}
+}
+
+func TestSplit_ExactMustMatchAmount(t *testing.T) {
+	_, err := Split(1000, SplitExact, []Share{{UserID: 1, Value: 600}, {UserID: 2, Value: 300}})
+	if err == nil {
+		t.Errorf("Expected exact split not adding up to the amount to fail")
+	}
+
+	_, err = Split(1000, SplitExact, []Share{{UserID: 1, Value: 600}, {UserID: 2, Value: 400}})
+	if err != nil {
+		t.Errorf("Expected exact split to succeed: %s", err)
+	}
+}
+
+func TestSplit_ExactRejectsOverflowingValues(t *testing.T) {
+	_, err := Split(1000, SplitExact, []Share{
+		{UserID: 1, Value: math.MaxInt64},
+		{UserID: 2, Value: math.MaxInt64},
+		{UserID: 3, Value: 1002},
+	})
+	if err == nil {
+		t.Errorf("Expected exact split with values wrapping around to the amount to fail")
+	}
+}
+
+func TestSplit_RejectsInvalidInput(t *testing.T) {
+	cases := []struct {
+		name         string
+		amount       int64
+		mode         string
+		participants []Share
+	}{
+		{"zero amount", 0, SplitEqual, []Share{{UserID: 1}}},
+		{"unknown mode", 100, "random", []Share{{UserID: 1}}},
+		{"no participants", 100, SplitEqual, nil},
+		{"duplicated user", 100, SplitEqual, []Share{{UserID: 1}, {UserID: 1}}},
+		{"zero weight", 100, SplitShares, []Share{{UserID: 1, Value: 0}}},
+	}
+
+	for _, c := range cases {
+		_, err := Split(c.amount, c.mode, c.participants)
+		if err == nil {
+			t.Errorf("%s: expected split to fail", c.name)
+		}
+	}
+}
+
+func TestSettle_ClearsAllBalances(t *testing.T) {
+	balances := map[int]int64{1: 500, 2: -200, 3: -300, 4: 100, 5: -100}
+
+	transfers := Settle(balances)
+
+	if len(transfers) > len(balances)-1 {
+		t.Errorf("Expected at most %d transfers, got %d", len(balances)-1, len(transfers))
+	}
+
+	for _, transfer := range transfers {
+		balances[transfer.From] += transfer.Amount
+		balances[transfer.To] -= transfer.Amount
+	}
+
+	for userID, balance := range balances {
+		if balance != 0 {
+			t.Errorf("Expected user %d to be settled, balance is %d", userID, balance)
+		}
+	}
+}
+
+func TestSettle_NoBalancesNoTransfers(t *testing.T) {
+	transfers := Settle(map[int]int64{1: 0, 2: 0})
+	if len(transfers) != 0 {
+		t.Errorf("Expected no transfers, got %v", transfers)
+	}
+}
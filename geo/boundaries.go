@@ -0,0 +1,211 @@
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+type (
+	// Boundaries holds the borders of every country, loaded from a GeoJSON FeatureCollection
+	Boundaries struct {
+		countries []countryBoundary
+		index     map[string]int
+	}
+
+	countryBoundary struct {
+		iso      string
+		polygons [][][][]float64
+		box      boundingBox
+	}
+
+	boundingBox struct {
+		minLat, maxLat, minLng, maxLng float64
+	}
+)
+
+// isoProperties are the feature properties that can hold the code of a country, in order of preference
+var isoProperties = []string{"iso", "ISO_A2_EH", "ISO_A2", "iso_a2", "ISO_A3", "iso_a3"}
+
+var (
+	errorNoBoundaries = errors.New("boundaries file does not have any country")
+)
+
+// LoadBoundaries reads a GeoJSON FeatureCollection where every feature is a Polygon or MultiPolygon
+// with the code of the country in one of the isoProperties, the code must match the iso column of the countries table
+func LoadBoundaries(reader io.Reader) (*Boundaries, error) {
+	var collection geoJSONObject
+
+	err := json.NewDecoder(reader).Decode(&collection)
+	if err != nil {
+		return nil, err
+	}
+
+	boundaries := &Boundaries{
+		index: make(map[string]int),
+	}
+
+	for _, feature := range collection.Features {
+		if feature.Geometry == nil {
+			continue
+		}
+
+		iso, _ := firstProperty(feature.Properties, isoProperties...).(string)
+		iso = strings.ToUpper(strings.TrimSpace(iso))
+
+		// natural earth uses -99 for territories without a code
+		if iso == "" || iso == "-99" {
+			continue
+		}
+
+		var polygons [][][][]float64
+
+		switch feature.Geometry.Type {
+		case "Polygon":
+			var polygon [][][]float64
+			err = json.Unmarshal(feature.Geometry.Coordinates, &polygon)
+			polygons = [][][][]float64{polygon}
+		case "MultiPolygon":
+			err = json.Unmarshal(feature.Geometry.Coordinates, &polygons)
+		default:
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		boundary := countryBoundary{
+			iso:      iso,
+			polygons: polygons,
+			box:      boundingBox{minLat: 90, maxLat: -90, minLng: 180, maxLng: -180},
+		}
+
+		for _, polygon := range polygons {
+			if len(polygon) == 0 {
+				continue
+			}
+
+			// the outer ring is enough to know the bounding box
+			for _, position := range polygon[0] {
+				if len(position) < 2 {
+					return nil, errorInvalidCoordinates
+				}
+
+				boundary.box.minLng = min(boundary.box.minLng, position[0])
+				boundary.box.maxLng = max(boundary.box.maxLng, position[0])
+				boundary.box.minLat = min(boundary.box.minLat, position[1])
+				boundary.box.maxLat = max(boundary.box.maxLat, position[1])
+			}
+		}
+
+		// some datasets split a country in more than one feature
+		if index, ok := boundaries.index[iso]; ok {
+			existing := &boundaries.countries[index]
+			existing.polygons = append(existing.polygons, boundary.polygons...)
+			existing.box = boundingBox{
+				minLat: min(existing.box.minLat, boundary.box.minLat),
+				maxLat: max(existing.box.maxLat, boundary.box.maxLat),
+				minLng: min(existing.box.minLng, boundary.box.minLng),
+				maxLng: max(existing.box.maxLng, boundary.box.maxLng),
+			}
+
+			continue
+		}
+
+		boundaries.index[iso] = len(boundaries.countries)
+		boundaries.countries = append(boundaries.countries, boundary)
+	}
+
+	if len(boundaries.countries) == 0 {
+		return nil, errorNoBoundaries
+	}
+
+	return boundaries, nil
+}
+
+// Country returns the code of the country that contains the position
+func (boundaries *Boundaries) Country(latitude float64, longitude float64) (string, bool) {
+	if boundaries == nil {
+		return "", false
+	}
+
+	for _, country := range boundaries.countries {
+		if latitude < country.box.minLat || latitude > country.box.maxLat ||
+			longitude < country.box.minLng || longitude > country.box.maxLng {
+			continue
+		}
+
+		for _, polygon := range country.polygons {
+			if inPolygon(polygon, latitude, longitude) {
+				return country.iso, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// Polygons returns the borders of the country as a list of polygons, each polygon is a list of rings
+// of [longitude, latitude] positions where the first ring is the outer border and the others are holes
+func (boundaries *Boundaries) Polygons(iso string) ([][][][]float64, bool) {
+	if boundaries == nil {
+		return nil, false
+	}
+
+	index, ok := boundaries.index[strings.ToUpper(iso)]
+	if !ok {
+		return nil, false
+	}
+
+	return boundaries.countries[index].polygons, true
+}
+
+// Center returns the center of the bounding box of the country, it is only meant to place a marker on a map
+func (boundaries *Boundaries) Center(iso string) (Point, bool) {
+	if boundaries == nil {
+		return Point{}, false
+	}
+
+	index, ok := boundaries.index[strings.ToUpper(iso)]
+	if !ok {
+		return Point{}, false
+	}
+
+	box := boundaries.countries[index].box
+
+	return Point{Latitude: (box.minLat + box.maxLat) / 2, Longitude: (box.minLng + box.maxLng) / 2}, true
+}
+
+// inPolygon checks that the position is inside the outer ring and outside every hole of the polygon
+func inPolygon(polygon [][][]float64, latitude float64, longitude float64) bool {
+	if len(polygon) == 0 || !inRing(polygon[0], latitude, longitude) {
+		return false
+	}
+
+	for _, hole := range polygon[1:] {
+		if inRing(hole, latitude, longitude) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// inRing uses ray casting, a position is inside when a ray from it crosses the ring an odd number of times
+func inRing(ring [][]float64, latitude float64, longitude float64) bool {
+	inside := false
+
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		lngI, latI := ring[i][0], ring[i][1]
+		lngJ, latJ := ring[j][0], ring[j][1]
+
+		if (latI > latitude) != (latJ > latitude) &&
+			longitude < (lngJ-lngI)*(latitude-latI)/(latJ-latI)+lngI {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
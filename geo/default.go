@@ -0,0 +1,31 @@
+package geo
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+)
+
+// data holds the datasets bundled with the server
+//
+//go:embed data
+var data embed.FS
+
+const defaultBoundariesFile = "data/countries.geojson"
+
+// DefaultBoundaries loads the simplified country borders bundled with the server,
+// nil is returned when the binary was built without them
+func DefaultBoundaries() (*Boundaries, error) {
+	file, err := data.Open(defaultBoundariesFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return LoadBoundaries(file)
+}
@@ -0,0 +1,19 @@
+package geo
+
+import "math"
+
+// earthRadius is the mean radius of the earth in kilometres
+const earthRadius = 6371.0088
+
+// Distance returns the great-circle distance in kilometres between two points using the haversine formula
+func Distance(from Point, to Point) float64 {
+	fromLat := from.Latitude * math.Pi / 180
+	toLat := to.Latitude * math.Pi / 180
+	deltaLat := (to.Latitude - from.Latitude) * math.Pi / 180
+	deltaLng := (to.Longitude - from.Longitude) * math.Pi / 180
+
+	a := math.Sin(deltaLat/2)*math.Sin(deltaLat/2) +
+		math.Cos(fromLat)*math.Cos(toLat)*math.Sin(deltaLng/2)*math.Sin(deltaLng/2)
+
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
@@ -0,0 +1,173 @@
+package geo
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// GeoJSONContentType is the media type of GeoJSON documents
+	GeoJSONContentType = "application/geo+json"
+	// KMLContentType is the media type of KML documents
+	KMLContentType = "application/vnd.google-earth.kml+xml"
+)
+
+// Feature is a named map element with its points and polygons, a feature without any of them has no geometry
+type Feature struct {
+	Name       string
+	Properties map[string]any
+	Points     []Point
+	Polygons   [][][][]float64
+}
+
+// WriteGeoJSON writes the features as a GeoJSON FeatureCollection
+func WriteGeoJSON(w io.Writer, features []Feature) error {
+	type geometry struct {
+		Type        string `json:"type"`
+		Coordinates any    `json:"coordinates,omitempty"`
+		Geometries  []any  `json:"geometries,omitempty"`
+	}
+
+	type feature struct {
+		Type       string         `json:"type"`
+		Geometry   *geometry      `json:"geometry"`
+		Properties map[string]any `json:"properties"`
+	}
+
+	collection := struct {
+		Type     string    `json:"type"`
+		Features []feature `json:"features"`
+	}{
+		Type:     "FeatureCollection",
+		Features: make([]feature, 0, len(features)),
+	}
+
+	for _, item := range features {
+		properties := map[string]any{"name": item.Name}
+		for key, value := range item.Properties {
+			properties[key] = value
+		}
+
+		var geometries []geometry
+
+		if len(item.Points) == 1 {
+			geometries = append(geometries, geometry{Type: "Point", Coordinates: position(item.Points[0])})
+		} else if len(item.Points) > 1 {
+			positions := make([][]float64, len(item.Points))
+			for i, point := range item.Points {
+				positions[i] = position(point)
+			}
+
+			geometries = append(geometries, geometry{Type: "MultiPoint", Coordinates: positions})
+		}
+
+		if len(item.Polygons) > 0 {
+			geometries = append(geometries, geometry{Type: "MultiPolygon", Coordinates: item.Polygons})
+		}
+
+		exported := feature{Type: "Feature", Properties: properties}
+
+		switch len(geometries) {
+		case 0:
+		case 1:
+			exported.Geometry = &geometries[0]
+		default:
+			collectionGeometry := geometry{Type: "GeometryCollection"}
+			for _, part := range geometries {
+				collectionGeometry.Geometries = append(collectionGeometry.Geometries, part)
+			}
+
+			exported.Geometry = &collectionGeometry
+		}
+
+		collection.Features = append(collection.Features, exported)
+	}
+
+	return json.NewEncoder(w).Encode(collection)
+}
+
+// WriteKML writes the features as placemarks of a KML document, properties are written as ExtendedData
+func WriteKML(w io.Writer, name string, features []Feature) error {
+	writer := bufio.NewWriter(w)
+
+	writer.WriteString(xml.Header)
+	writer.WriteString(`<kml xmlns="http://www.opengis.net/kml/2.2"><Document>`)
+	writer.WriteString("<name>" + escapeXML(name) + "</name>")
+
+	for _, feature := range features {
+		writer.WriteString("<Placemark><name>" + escapeXML(feature.Name) + "</name>")
+
+		if len(feature.Properties) > 0 {
+			keys := make([]string, 0, len(feature.Properties))
+			for key := range feature.Properties {
+				keys = append(keys, key)
+			}
+
+			sort.Strings(keys)
+
+			writer.WriteString("<ExtendedData>")
+			for _, key := range keys {
+				writer.WriteString(`<Data name="` + escapeXML(key) + `"><value>` +
+					escapeXML(fmt.Sprint(feature.Properties[key])) + "</value></Data>")
+			}
+			writer.WriteString("</ExtendedData>")
+		}
+
+		if len(feature.Points) > 0 || len(feature.Polygons) > 0 {
+			writer.WriteString("<MultiGeometry>")
+
+			for _, point := range feature.Points {
+				writer.WriteString("<Point><coordinates>" + kmlCoordinate(position(point)) + "</coordinates></Point>")
+			}
+
+			for _, polygon := range feature.Polygons {
+				writer.WriteString("<Polygon>")
+
+				for i, ring := range polygon {
+					boundary := "innerBoundaryIs"
+					if i == 0 {
+						boundary = "outerBoundaryIs"
+					}
+
+					coordinates := make([]string, len(ring))
+					for j, ringPosition := range ring {
+						coordinates[j] = kmlCoordinate(ringPosition)
+					}
+
+					writer.WriteString("<" + boundary + "><LinearRing><coordinates>" +
+						strings.Join(coordinates, " ") + "</coordinates></LinearRing></" + boundary + ">")
+				}
+
+				writer.WriteString("</Polygon>")
+			}
+
+			writer.WriteString("</MultiGeometry>")
+		}
+
+		writer.WriteString("</Placemark>")
+	}
+
+	writer.WriteString("</Document></kml>\n")
+
+	return writer.Flush()
+}
+
+func position(point Point) []float64 {
+	return []float64{point.Longitude, point.Latitude}
+}
+
+func kmlCoordinate(position []float64) string {
+	return strconv.FormatFloat(position[0], 'f', -1, 64) + "," + strconv.FormatFloat(position[1], 'f', -1, 64)
+}
+
+func escapeXML(value string) string {
+	var builder strings.Builder
+	xml.EscapeText(&builder, []byte(value))
+	return builder.String()
+}
@@ -0,0 +1,200 @@
+package geo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// two squares side by side, AA from longitude 0 to 10 and BB from 10 to 20, AA has a hole around 5,5
+const testBoundaries = `{
+	"type": "FeatureCollection",
+	"features": [
+		{"type": "Feature", "properties": {"iso": "AA"}, "geometry": {"type": "Polygon", "coordinates": [
+			[[0, 0], [10, 0], [10, 10], [0, 10], [0, 0]],
+			[[4, 4], [6, 4], [6, 6], [4, 6], [4, 4]]
+		]}},
+		{"type": "Feature", "properties": {"ISO_A2": "bb"}, "geometry": {"type": "MultiPolygon", "coordinates": [
+			[[[10, 0], [20, 0], [20, 10], [10, 10], [10, 0]]]
+		]}}
+	]
+}`
+
+func loadTestBoundaries(t *testing.T) *Boundaries {
+	boundaries, err := LoadBoundaries(strings.NewReader(testBoundaries))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return boundaries
+}
+
+func TestBoundaries_Country(t *testing.T) {
+	boundaries := loadTestBoundaries(t)
+
+	tests := []struct {
+		latitude, longitude float64
+		expected            string
+		found               bool
+	}{
+		{2, 2, "AA", true},
+		{5, 5, "", false},
+		{2, 15, "BB", true},
+		{-5, 5, "", false},
+	}
+
+	for _, test := range tests {
+		country, found := boundaries.Country(test.latitude, test.longitude)
+		if country != test.expected || found != test.found {
+			t.Errorf("Expected %q %v for %v,%v, got %q %v", test.expected, test.found, test.latitude, test.longitude, country, found)
+		}
+	}
+}
+
+func TestParse_GPX(t *testing.T) {
+	data := []byte(`<?xml version="1.0"?>
+<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1">
+	<trk><name>Road trip</name><trkseg>
+		<trkpt lat="2" lon="2"><time>2024-05-01T10:00:00Z</time></trkpt>
+		<trkpt lat="3" lon="15"><time>2024-05-03T10:00:00Z</time></trkpt>
+	</trkseg></trk>
+</gpx>`)
+
+	format, err := DetectFormat(data)
+	if err != nil || format != FormatGPX {
+		t.Fatalf("Expected gpx format, got %q %v", format, err)
+	}
+
+	track, err := Parse(data, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if track.Name != "Road trip" || len(track.Points) != 2 {
+		t.Fatalf("Unexpected track %+v", track)
+	}
+
+	visits, err := Visits(track, loadTestBoundaries(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(visits) != 2 || visits[0].Country != "AA" || visits[1].Country != "BB" {
+		t.Fatalf("Unexpected visits %+v", visits)
+	}
+
+	if !visits[1].Arrival.Equal(time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected arrival %v", visits[1].Arrival)
+	}
+}
+
+func TestParse_KMLTrack(t *testing.T) {
+	data := []byte(`<kml xmlns="http://www.opengis.net/kml/2.2" xmlns:gx="http://www.google.com/kml/ext/2.2">
+<Document><name>Holiday</name><Placemark><gx:Track>
+	<when>2024-05-01T10:00:00Z</when><when>2024-05-02T10:00:00Z</when>
+	<gx:coord>2 2 0</gx:coord><gx:coord>3 2 0</gx:coord>
+</gx:Track></Placemark>
+<Placemark><TimeStamp><when>2024-05-04</when></TimeStamp><Point><coordinates>15,2,0</coordinates></Point></Placemark>
+</Document></kml>`)
+
+	format, err := DetectFormat(data)
+	if err != nil || format != FormatKML {
+		t.Fatalf("Expected kml format, got %q %v", format, err)
+	}
+
+	track, err := Parse(data, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if track.Name != "Holiday" || len(track.Points) != 3 {
+		t.Fatalf("Unexpected track %+v", track)
+	}
+
+	if track.Points[2].Time.IsZero() || track.Points[1].Time.IsZero() {
+		t.Errorf("Expected every point to have a time, got %+v", track.Points)
+	}
+}
+
+func TestParse_GeoJSONLine(t *testing.T) {
+	data := []byte(`{"type": "Feature", "properties": {"name": "Walk", "coordTimes": ["2024-05-01T10:00:00Z", "2024-05-01T12:00:00Z"]},
+		"geometry": {"type": "LineString", "coordinates": [[2, 2], [15, 2]]}}`)
+
+	format, err := DetectFormat(data)
+	if err != nil || format != FormatGeoJSON {
+		t.Fatalf("Expected geojson format, got %q %v", format, err)
+	}
+
+	track, err := Parse(data, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(track.Points) != 2 || track.Points[1].Latitude != 2 || track.Points[1].Longitude != 15 || track.Points[1].Time.Hour() != 12 {
+		t.Fatalf("Unexpected track %+v", track)
+	}
+}
+
+func TestVisits_NeedsTimestamps(t *testing.T) {
+	_, err := Visits(Track{Points: []Point{{Latitude: 2, Longitude: 2}}}, loadTestBoundaries(t))
+	if err == nil {
+		t.Error("Expected an error for a track without timestamps")
+	}
+}
+
+func TestWriteGeoJSON_CanBeParsedBack(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := WriteGeoJSON(&buf, []Feature{
+		{Name: "Lisbon", Points: []Point{{Latitude: 38.7, Longitude: -9.1}}},
+		{Name: "AA", Properties: map[string]any{"trips": 2}, Polygons: [][][][]float64{{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}}},
+		{Name: "Nowhere"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	track, err := Parse(buf.Bytes(), FormatGeoJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(track.Points) != 5 || track.Points[0].Latitude != 38.7 {
+		t.Errorf("Unexpected points %+v", track.Points)
+	}
+}
+
+func TestWriteKML_CanBeParsedBack(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := WriteKML(&buf, "Map & trips", []Feature{
+		{Name: "<Lisbon>", Properties: map[string]any{"startdate": "2024-05-01"}, Points: []Point{{Latitude: 38.7, Longitude: -9.1}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	track, err := Parse(buf.Bytes(), FormatKML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if track.Name != "Map & trips" || len(track.Points) != 1 || track.Points[0].Longitude != -9.1 {
+		t.Errorf("Unexpected track %+v", track)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	lisbon := Point{Latitude: 38.7223, Longitude: -9.1393}
+	madrid := Point{Latitude: 40.4168, Longitude: -3.7038}
+
+	distance := Distance(lisbon, madrid)
+	if distance < 500 || distance > 506 {
+		t.Errorf("Expected around 503km between Lisbon and Madrid, got %v", distance)
+	}
+
+	if Distance(lisbon, lisbon) != 0 {
+		t.Errorf("Expected no distance between the same point, got %v", Distance(lisbon, lisbon))
+	}
+}
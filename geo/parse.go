@@ -0,0 +1,425 @@
+package geo
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// gpxFile is the blueprint for the parts of a gpx document we read, namespaces are ignored
+	gpxFile struct {
+		Name   string     `xml:"metadata>name"`
+		Tracks []gpxTrack `xml:"trk"`
+		Routes []gpxRoute `xml:"rte"`
+		Points []gpxPoint `xml:"wpt"`
+	}
+
+	gpxTrack struct {
+		Name     string `xml:"name"`
+		Segments []struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	}
+
+	gpxRoute struct {
+		Name   string     `xml:"name"`
+		Points []gpxPoint `xml:"rtept"`
+	}
+
+	gpxPoint struct {
+		Latitude  float64 `xml:"lat,attr"`
+		Longitude float64 `xml:"lon,attr"`
+		Time      string  `xml:"time"`
+	}
+
+	// geoJSONObject is the blueprint for any GeoJSON object, only the fields of its type are set
+	geoJSONObject struct {
+		Type        string          `json:"type"`
+		Features    []geoJSONObject `json:"features"`
+		Geometry    *geoJSONObject  `json:"geometry"`
+		Geometries  []geoJSONObject `json:"geometries"`
+		Coordinates json.RawMessage `json:"coordinates"`
+		Properties  map[string]any  `json:"properties"`
+	}
+)
+
+var (
+	errorInvalidCoordinates = errors.New("invalid coordinates")
+)
+
+// xmlRoot returns the local name of the root element of an xml document
+func xmlRoot(data []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", errorUnknownFormat
+		}
+
+		if start, ok := token.(xml.StartElement); ok {
+			return strings.ToLower(start.Name.Local), nil
+		}
+	}
+}
+
+func parseGPX(data []byte) (Track, error) {
+	var file gpxFile
+
+	err := xml.Unmarshal(data, &file)
+	if err != nil {
+		return Track{}, err
+	}
+
+	track := Track{Name: file.Name}
+
+	add := func(name string, points []gpxPoint) {
+		if track.Name == "" {
+			track.Name = name
+		}
+
+		for _, point := range points {
+			track.Points = append(track.Points, Point{
+				Latitude:  point.Latitude,
+				Longitude: point.Longitude,
+				Time:      parseTime(point.Time),
+			})
+		}
+	}
+
+	for _, gpxTrack := range file.Tracks {
+		for _, segment := range gpxTrack.Segments {
+			add(gpxTrack.Name, segment.Points)
+		}
+	}
+
+	for _, route := range file.Routes {
+		add(route.Name, route.Points)
+	}
+
+	add("", file.Points)
+
+	return track, nil
+}
+
+// parseKML reads the coordinates of every placemark, times come from gx:Track when/coord pairs,
+// a TimeStamp that applies to every coordinate of the placemark or a TimeSpan that applies to its first and last one
+func parseKML(data []byte) (Track, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	var track Track
+	var stack []string
+
+	var placemark struct {
+		points    []Point
+		trackWhen []time.Time
+		trackPts  []Point
+		stamp     time.Time
+		begin     time.Time
+		end       time.Time
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return Track{}, err
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			stack = append(stack, element.Name.Local)
+
+			if element.Name.Local == "Placemark" {
+				placemark.points = nil
+				placemark.trackWhen = nil
+				placemark.trackPts = nil
+				placemark.stamp = time.Time{}
+				placemark.begin = time.Time{}
+				placemark.end = time.Time{}
+			}
+		case xml.EndElement:
+			if element.Name.Local == "Placemark" {
+				for i, point := range placemark.trackPts {
+					if i < len(placemark.trackWhen) {
+						point.Time = placemark.trackWhen[i]
+					}
+
+					track.Points = append(track.Points, point)
+				}
+
+				for i, point := range placemark.points {
+					switch {
+					case !placemark.stamp.IsZero():
+						point.Time = placemark.stamp
+					case i == 0 && !placemark.begin.IsZero():
+						point.Time = placemark.begin
+					case i == len(placemark.points)-1 && !placemark.end.IsZero():
+						point.Time = placemark.end
+					}
+
+					track.Points = append(track.Points, point)
+				}
+			}
+
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) < 2 {
+				continue
+			}
+
+			text := strings.TrimSpace(string(element))
+			current, parent := stack[len(stack)-1], stack[len(stack)-2]
+
+			switch {
+			case current == "name" && track.Name == "" && (parent == "Document" || parent == "Placemark" || parent == "Folder"):
+				track.Name = text
+			case current == "coordinates":
+				points, err := parseKMLCoordinates(text)
+				if err != nil {
+					return Track{}, err
+				}
+
+				placemark.points = append(placemark.points, points...)
+			case current == "coord" && parent == "Track":
+				fields := strings.Fields(text)
+				if len(fields) < 2 {
+					return Track{}, errorInvalidCoordinates
+				}
+
+				point, err := newPoint(fields[0], fields[1])
+				if err != nil {
+					return Track{}, err
+				}
+
+				placemark.trackPts = append(placemark.trackPts, point)
+			case current == "when" && parent == "Track":
+				placemark.trackWhen = append(placemark.trackWhen, parseTime(text))
+			case current == "when" && parent == "TimeStamp":
+				placemark.stamp = parseTime(text)
+			case current == "begin" && parent == "TimeSpan":
+				placemark.begin = parseTime(text)
+			case current == "end" && parent == "TimeSpan":
+				placemark.end = parseTime(text)
+			}
+		}
+	}
+
+	return track, nil
+}
+
+// parseKMLCoordinates reads a list of "longitude,latitude[,altitude]" tuples separated by white space
+func parseKMLCoordinates(text string) ([]Point, error) {
+	var points []Point
+
+	for _, tuple := range strings.Fields(text) {
+		values := strings.Split(tuple, ",")
+		if len(values) < 2 {
+			return nil, errorInvalidCoordinates
+		}
+
+		point, err := newPoint(values[0], values[1])
+		if err != nil {
+			return nil, err
+		}
+
+		points = append(points, point)
+	}
+
+	return points, nil
+}
+
+// parseGeoJSON reads every point and line of the document, times come from the "time" or "timestamp"
+// property of points and from the "coordTimes" or "times" property of lines
+func parseGeoJSON(data []byte) (Track, error) {
+	var object geoJSONObject
+
+	err := json.Unmarshal(data, &object)
+	if err != nil {
+		return Track{}, err
+	}
+
+	var track Track
+
+	err = walkGeoJSON(object, nil, func(geometry geoJSONObject, properties map[string]any) error {
+		if track.Name == "" {
+			track.Name, _ = properties["name"].(string)
+		}
+
+		lines, err := geometryLines(geometry)
+		if err != nil {
+			return err
+		}
+
+		times := propertyTimes(properties)
+
+		for i, line := range lines {
+			var lineTimes []any
+
+			// multi geometries have a list of times for each of their lines
+			if len(lines) > 1 && i < len(times) {
+				lineTimes, _ = times[i].([]any)
+			} else if len(lines) == 1 {
+				lineTimes = times
+			}
+
+			for j, position := range line {
+				point := Point{Longitude: position[0], Latitude: position[1]}
+
+				if j < len(lineTimes) {
+					point.Time = anyTime(lineTimes[j])
+				} else if len(line) == 1 {
+					point.Time = anyTime(firstProperty(properties, "time", "timestamp"))
+				}
+
+				track.Points = append(track.Points, point)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return Track{}, err
+	}
+
+	return track, nil
+}
+
+// walkGeoJSON calls found for every geometry of the object with the properties of the feature it belongs to
+func walkGeoJSON(object geoJSONObject, properties map[string]any, found func(geoJSONObject, map[string]any) error) error {
+	switch object.Type {
+	case "FeatureCollection":
+		for _, feature := range object.Features {
+			err := walkGeoJSON(feature, nil, found)
+			if err != nil {
+				return err
+			}
+		}
+	case "Feature":
+		if object.Geometry != nil {
+			return walkGeoJSON(*object.Geometry, object.Properties, found)
+		}
+	case "GeometryCollection":
+		for _, geometry := range object.Geometries {
+			err := walkGeoJSON(geometry, properties, found)
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return found(object, properties)
+	}
+
+	return nil
+}
+
+// geometryLines returns the positions of a geometry as a list of lines, polygons are read by their rings
+func geometryLines(geometry geoJSONObject) ([][][]float64, error) {
+	var err error
+	var lines [][][]float64
+
+	switch geometry.Type {
+	case "Point":
+		var position []float64
+		err = json.Unmarshal(geometry.Coordinates, &position)
+		lines = [][][]float64{{position}}
+	case "MultiPoint", "LineString":
+		var line [][]float64
+		err = json.Unmarshal(geometry.Coordinates, &line)
+		lines = [][][]float64{line}
+	case "MultiLineString", "Polygon":
+		err = json.Unmarshal(geometry.Coordinates, &lines)
+	case "MultiPolygon":
+		var polygons [][][][]float64
+		err = json.Unmarshal(geometry.Coordinates, &polygons)
+		for _, polygon := range polygons {
+			lines = append(lines, polygon...)
+		}
+	default:
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range lines {
+		for _, position := range line {
+			if len(position) < 2 {
+				return nil, errorInvalidCoordinates
+			}
+		}
+	}
+
+	return lines, nil
+}
+
+func propertyTimes(properties map[string]any) []any {
+	times, _ := firstProperty(properties, "coordTimes", "times").([]any)
+	return times
+}
+
+func firstProperty(properties map[string]any, keys ...string) any {
+	for _, key := range keys {
+		if value, ok := properties[key]; ok {
+			return value
+		}
+	}
+
+	return nil
+}
+
+// anyTime reads a time from a json value, strings are parsed as RFC 3339 and numbers as unix seconds or milliseconds
+func anyTime(value any) time.Time {
+	switch typed := value.(type) {
+	case string:
+		return parseTime(typed)
+	case float64:
+		if typed > 1e12 {
+			return time.UnixMilli(int64(typed)).UTC()
+		}
+
+		return time.Unix(int64(typed), 0).UTC()
+	}
+
+	return time.Time{}
+}
+
+// parseTime reads a timestamp, values without a time zone are read as UTC, invalid values return the zero time
+func parseTime(value string) time.Time {
+	value = strings.TrimSpace(value)
+
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", time.DateOnly} {
+		parsed, err := time.Parse(layout, value)
+		if err == nil {
+			return parsed.UTC()
+		}
+	}
+
+	return time.Time{}
+}
+
+func newPoint(longitude string, latitude string) (Point, error) {
+	lng, err := strconv.ParseFloat(strings.TrimSpace(longitude), 64)
+	if err != nil {
+		return Point{}, err
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(latitude), 64)
+	if err != nil {
+		return Point{}, err
+	}
+
+	return Point{Latitude: lat, Longitude: lng}, nil
+}
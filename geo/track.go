@@ -0,0 +1,156 @@
+package geo
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"time"
+)
+
+const (
+	// FormatGPX is the GPS exchange format
+	FormatGPX = "gpx"
+	// FormatKML is the keyhole markup language used by Google Earth and most map apps
+	FormatKML = "kml"
+	// FormatGeoJSON is the GeoJSON format (RFC 7946)
+	FormatGeoJSON = "geojson"
+
+	// maxSamples is the amount of points looked up in the boundaries, bigger tracks are sampled evenly
+	maxSamples = 2000
+)
+
+var (
+	errorUnknownFormat = errors.New("file is not a gpx, kml or geojson document")
+	errorNoPoints      = errors.New("file does not have any point")
+	errorNoTimestamps  = errors.New("file does not have any timestamped point")
+	errorInvalidPoint  = errors.New("point coordinates are out of range")
+)
+
+// Point is a single recorded position, Time is zero when the file did not have one
+type Point struct {
+	Latitude  float64
+	Longitude float64
+	Time      time.Time
+}
+
+// Track is every point read from a file, in the order they were written
+type Track struct {
+	Name   string
+	Points []Point
+}
+
+// Visit is a continuous stay in a single country
+type Visit struct {
+	Country   string
+	Arrival   time.Time
+	Departure time.Time
+}
+
+// DetectFormat looks at the start of the file to find out which format it is written in
+func DetectFormat(data []byte) (string, error) {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return FormatGeoJSON, nil
+	}
+
+	if !bytes.HasPrefix(trimmed, []byte("<")) {
+		return "", errorUnknownFormat
+	}
+
+	root, err := xmlRoot(trimmed)
+	if err != nil {
+		return "", err
+	}
+
+	switch root {
+	case "gpx":
+		return FormatGPX, nil
+	case "kml":
+		return FormatKML, nil
+	}
+
+	return "", errorUnknownFormat
+}
+
+// Parse reads a track from a file in the given format
+func Parse(data []byte, format string) (Track, error) {
+	var track Track
+	var err error
+
+	switch format {
+	case FormatGPX:
+		track, err = parseGPX(data)
+	case FormatKML:
+		track, err = parseKML(data)
+	case FormatGeoJSON:
+		track, err = parseGeoJSON(data)
+	default:
+		return Track{}, errorUnknownFormat
+	}
+
+	if err != nil {
+		return Track{}, err
+	}
+
+	if len(track.Points) == 0 {
+		return Track{}, errorNoPoints
+	}
+
+	for _, point := range track.Points {
+		if point.Latitude < -90 || point.Latitude > 90 || point.Longitude < -180 || point.Longitude > 180 {
+			return Track{}, errorInvalidPoint
+		}
+	}
+
+	return track, nil
+}
+
+// Visits finds the countries crossed by the track in chronological order, points without a timestamp
+// and points that are not inside any country (e.g. at sea) are ignored
+func Visits(track Track, boundaries *Boundaries) ([]Visit, error) {
+	var points []Point
+
+	for _, point := range track.Points {
+		if !point.Time.IsZero() {
+			points = append(points, point)
+		}
+	}
+
+	if len(points) == 0 {
+		return nil, errorNoTimestamps
+	}
+
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+
+	// sample big tracks evenly, always keeping the last point so the departure is not lost
+	if len(points) > maxSamples {
+		step := float64(len(points)-1) / float64(maxSamples-1)
+		sampled := make([]Point, maxSamples)
+
+		for i := range sampled {
+			sampled[i] = points[int(float64(i)*step)]
+		}
+
+		sampled[maxSamples-1] = points[len(points)-1]
+		points = sampled
+	}
+
+	var visits []Visit
+
+	for _, point := range points {
+		country, ok := boundaries.Country(point.Latitude, point.Longitude)
+		if !ok {
+			continue
+		}
+
+		if len(visits) > 0 && visits[len(visits)-1].Country == country {
+			visits[len(visits)-1].Departure = point.Time
+			continue
+		}
+
+		visits = append(visits, Visit{Country: country, Arrival: point.Time, Departure: point.Time})
+	}
+
+	return visits, nil
+}
@@ -298,6 +298,40 @@ func (handler *Handler) PrivacyStatusHandler(w http.ResponseWriter, r *http.Requ
 	deferredErr = writeServerResponse(w, true, "")
 }
 
+func (handler *Handler) TripVisibilityHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	visibility, deferredErr := strconv.Atoi(r.URL.Query().Get(visibilityParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	if _, ok := tripVisibilities[visibility]; !ok {
+		deferredErr = errorInvalidRequestData
+		return
+	}
+
+	deferredErr = handler.database.ExecQuery(db.UpdateTripVisibility, visibility, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, "")
+}
+
 func (handler *Handler) CloseAccountHandler(w http.ResponseWriter, r *http.Request) {
 	var deferredErr error
 	defer func() {
@@ -410,7 +444,13 @@ func (handler *Handler) RegisterHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if len(registerRequest.FullName) >= 45 {
+	if len(registerRequest.FullName) >= maxFullNameLength {
+		deferredErr = errorInvalidRequestData
+		return
+	}
+
+	registerRequest.Username = normaliseUsername(registerRequest.Username)
+	if !usernameFormat.MatchString(registerRequest.Username) {
 		deferredErr = errorInvalidRequestData
 		return
 	}
@@ -449,26 +489,28 @@ func (handler *Handler) RegisterHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	availability, deferredErr := handler.usernameAvailability(registerRequest.Username, 0, languageID)
+	if deferredErr != nil {
+		return
+	}
+
+	if !availability.Available {
+		deferredErr = writeServerResponse(w, false, availability)
+		return
+	}
+
 	hashedPassword, deferredErr := auth.HashPassword(registerRequest.Password)
 	if deferredErr != nil {
 		return
 	}
 
 	activationCode := generateRandomString()
-	timestamp := time.Now().Format("020106150405")
-	dobFormatted := dateOfBirth.Format("020106")
-
-	var username strings.Builder
-	username.WriteString("@")
-	username.WriteString(strings.ReplaceAll(strings.ToLower(registerRequest.FullName), " ", ""))
-	username.WriteString(dobFormatted)
-	username.WriteString(timestamp)
 
 	deferredErr = handler.database.ExecTransaction(
 		[]db.Transaction{
 			{
 				Query:  db.AddNewUser,
-				Params: []any{registerRequest.Email, hashedPassword, registerRequest.FullName, registerRequest.DoB, registerRequest.Country, username.String()},
+				Params: []any{registerRequest.Email, hashedPassword, registerRequest.FullName, registerRequest.DoB, registerRequest.Country, registerRequest.Username},
 			},
 			{
 				Query:  db.AddUserFlags,
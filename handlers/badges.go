@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+
+	"memtravel/badges"
+	"memtravel/db"
+	"memtravel/middleware"
+)
+
+// Badge is the blueprint for a badge awarded to a user and the day it was earned
+type Badge struct {
+	BadgeID     string `json:"badgeid"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Earned      string `json:"earned"`
+}
+
+func (handler *Handler) GetUserBadgesHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	ownerID, deferredErr := strconv.Atoi(r.PathValue(pathParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	audience, visibilities, deferredErr := handler.tripLevels(userID, ownerID)
+	if deferredErr != nil {
+		return
+	}
+
+	userBadges, deferredErr := handler.userBadges(ownerID, audience, visibilities)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, userBadges)
+}
+
+// RefreshBadges evaluates every badge definition against the trips of the user and stores the badges earned,
+// badges already awarded keep their date and badges no longer earned are taken back
+func (handler *Handler) RefreshBadges(userID int, today time.Time) error {
+	stops, err := handler.badgeStops(userID, audienceLevels[audienceOwner])
+	if err != nil {
+		return err
+	}
+
+	earned := badges.Evaluate(stops, badges.Definitions, today)
+
+	ids := make([]string, len(earned))
+	dates := make([]string, len(earned))
+
+	for i, badge := range earned {
+		ids[i] = badge.ID
+		dates[i] = badge.Date.Format(time.DateOnly)
+	}
+
+	return handler.database.ExecTransaction([]db.Transaction{
+		{
+			Query:  db.RemoveUnearnedBadges,
+			Params: []any{userID, pq.Array(ids)},
+		},
+		{
+			Query:  db.AddEarnedBadges,
+			Params: []any{userID, pq.Array(ids), pq.Array(dates)},
+		},
+	})
+}
+
+// badgeStops reads the places and dates of the trips of the user with the given visibility levels
+func (handler *Handler) badgeStops(userID int, visibilities []int64) ([]badges.Stop, error) {
+	rows, err := handler.database.Query(db.GetBadgeStops, userID, pq.Array(visibilities))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	stops := []badges.Stop{}
+
+	for rows.Next() {
+		var stop badges.Stop
+
+		err = rows.Scan(&stop.TripID, &stop.Country, &stop.Continent, &stop.City, &stop.Start, &stop.End)
+		if err != nil {
+			return nil, err
+		}
+
+		stops = append(stops, stop)
+	}
+
+	return stops, rows.Err()
+}
+
+// userBadges returns the badges of the owner shown to the audience, the stored badges count every trip so anyone
+// other than the owner gets the badges earned by the trips listed to them, or they would reveal the hidden ones
+func (handler *Handler) userBadges(ownerID int, audience string, visibilities []int64) ([]Badge, error) {
+	if audience == audienceOwner {
+		return handler.storedBadges(ownerID)
+	}
+
+	stops, err := handler.badgeStops(ownerID, visibilities)
+	if err != nil {
+		return nil, err
+	}
+
+	userBadges := []Badge{}
+
+	for _, earned := range badges.Evaluate(stops, badges.Definitions, time.Now()) {
+		definition, ok := badges.Lookup(earned.ID)
+		if !ok {
+			continue
+		}
+
+		userBadges = append(userBadges, Badge{
+			BadgeID:     definition.ID,
+			Name:        definition.Name,
+			Description: definition.Description,
+			Earned:      earned.Date.Format(time.DateOnly),
+		})
+	}
+
+	return userBadges, nil
+}
+
+// storedBadges reads the badges awarded to the user, badges whose definition was removed are skipped
+func (handler *Handler) storedBadges(userID int) ([]Badge, error) {
+	rows, err := handler.database.Query(db.GetUserBadges, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	userBadges := []Badge{}
+
+	for rows.Next() {
+		var badgeID string
+		var earned time.Time
+
+		err = rows.Scan(&badgeID, &earned)
+		if err != nil {
+			return nil, err
+		}
+
+		definition, ok := badges.Lookup(badgeID)
+		if !ok {
+			continue
+		}
+
+		userBadges = append(userBadges, Badge{
+			BadgeID:     definition.ID,
+			Name:        definition.Name,
+			Description: definition.Description,
+			Earned:      earned.Format(time.DateOnly),
+		})
+	}
+
+	return userBadges, rows.Err()
+}
@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"memtravel/db"
+	"memtravel/middleware"
+)
+
+type (
+	// BucketEntry is the blueprint for a destination in the bucket list of a user, an entry without a city
+	// is a whole country, TickedOff is set once a trip to the destination started and VisitedDate is its first day
+	BucketEntry struct {
+		EntryID     int    `json:"entryid,omitempty"`
+		Country     int    `json:"country"`
+		City        string `json:"city,omitempty"`
+		Priority    int    `json:"priority"`
+		Notes       string `json:"notes,omitempty"`
+		Position    int    `json:"position,omitempty"`
+		TickedOff   bool   `json:"tickedoff"`
+		VisitedDate string `json:"visiteddate,omitempty"`
+	}
+
+	// BucketOrder is the blueprint for the new order of the bucket list, it must hold every entry id exactly once
+	BucketOrder struct {
+		Entries []int `json:"entries"`
+	}
+)
+
+const (
+	bucketPriorityHigh = iota + 1
+	bucketPriorityMedium
+	bucketPriorityLow
+)
+
+const (
+	maxBucketNotesLength = 500
+	maxBucketEntries     = 200
+)
+
+var (
+	errorBucketEntryExists = errors.New("destination is already in the bucket list")
+	errorBucketListFull    = errors.New("bucket list has reached the maximum number of entries")
+)
+
+var bucketPriorities = map[int]struct{}{
+	bucketPriorityHigh:   {},
+	bucketPriorityMedium: {},
+	bucketPriorityLow:    {},
+}
+
+func (handler *Handler) GetBucketListHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	ownerID, deferredErr := strconv.Atoi(r.PathValue(pathParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	// entries are only ticked off by the trips listed to the user so hidden trips are not revealed
+	audience, visibilities, deferredErr := handler.tripLevels(userID, ownerID)
+	if deferredErr != nil {
+		return
+	}
+
+	// the bucket list is part of the profile so it is only shared with friends, like the wishlist of the map
+	if !friendAudience(audience) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	entries, deferredErr := handler.bucketList(ownerID, visibilities)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, entries)
+}
+
+func (handler *Handler) AddBucketEntryHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	var entry BucketEntry
+
+	deferredErr = readBody(r, &entry)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = validateBucketEntry(&entry)
+	if deferredErr != nil {
+		return
+	}
+
+	var total int
+
+	deferredErr = handler.database.QueryRow(db.CountBucketEntries, userID).Scan(&total)
+	if deferredErr != nil {
+		return
+	}
+
+	if total >= maxBucketEntries {
+		deferredErr = errorBucketListFull
+		return
+	}
+
+	// the same destination can only be added once, the unique key is (userid, country, city)
+	deferredErr = handler.database.QueryRow(
+		db.AddBucketEntry,
+		userID,
+		entry.Country,
+		entry.City,
+		entry.Priority,
+		entry.Notes,
+	).Scan(&entry.EntryID)
+	if deferredErr == sql.ErrNoRows {
+		deferredErr = errorBucketEntryExists
+		return
+	}
+
+	if deferredErr != nil {
+		return
+	}
+
+	invalidateMapCache(userID)
+
+	ownerID, deferredErr := strconv.Atoi(fmt.Sprint(userID))
+	if deferredErr != nil {
+		return
+	}
+
+	entries, deferredErr := handler.bucketList(ownerID, audienceLevels[audienceOwner])
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, entries)
+}
+
+func (handler *Handler) RemoveBucketEntryHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	entryID, deferredErr := strconv.Atoi(r.PathValue(pathParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.database.ExecQuery(db.RemoveBucketEntry, entryID, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	invalidateMapCache(userID)
+
+	deferredErr = writeServerResponse(w, true, nil)
+}
+
+func (handler *Handler) ReorderBucketListHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	ownerID, deferredErr := strconv.Atoi(fmt.Sprint(userID))
+	if deferredErr != nil {
+		return
+	}
+
+	var order BucketOrder
+
+	deferredErr = readBody(r, &order)
+	if deferredErr != nil {
+		return
+	}
+
+	current, deferredErr := handler.bucketList(ownerID, audienceLevels[audienceOwner])
+	if deferredErr != nil {
+		return
+	}
+
+	if len(order.Entries) != len(current) {
+		deferredErr = errorInvalidRequestData
+		return
+	}
+
+	existing := make(map[int]struct{}, len(current))
+	for _, entry := range current {
+		existing[entry.EntryID] = struct{}{}
+	}
+
+	transactions := make([]db.Transaction, 0, len(order.Entries))
+
+	for i, entryID := range order.Entries {
+		if _, ok := existing[entryID]; !ok {
+			deferredErr = fmt.Errorf("%d entry is not part of the bucket list of %d or is repeated", entryID, ownerID)
+			return
+		}
+
+		delete(existing, entryID)
+
+		transactions = append(transactions, db.Transaction{
+			Query:  db.UpdateBucketEntryPosition,
+			Params: []any{i + 1, entryID, ownerID},
+		})
+	}
+
+	if len(transactions) > 0 {
+		deferredErr = handler.database.ExecTransaction(transactions)
+		if deferredErr != nil {
+			return
+		}
+	}
+
+	entries, deferredErr := handler.bucketList(ownerID, audienceLevels[audienceOwner])
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, entries)
+}
+
+// bucketList reads the bucket list of the owner in the order chosen by the owner,
+// entries are ticked off by the trips and legs of the owner with the given visibility levels that already started
+func (handler *Handler) bucketList(ownerID int, visibilities []int64) ([]BucketEntry, error) {
+	rows, err := handler.database.Query(db.GetBucketList, ownerID, pq.Array(visibilities))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	entries := []BucketEntry{}
+
+	for rows.Next() {
+		var entry BucketEntry
+		var visitedDate sql.NullTime
+
+		err = rows.Scan(
+			&entry.EntryID,
+			&entry.Country,
+			&entry.City,
+			&entry.Priority,
+			&entry.Notes,
+			&entry.Position,
+			&visitedDate,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if visitedDate.Valid {
+			entry.TickedOff = true
+			entry.VisitedDate = visitedDate.Time.Format(time.DateOnly)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// validateBucketEntry checks the bucket list entry request data and normalises it before it is stored
+func validateBucketEntry(entry *BucketEntry) error {
+	if entry.Country <= 0 {
+		return errorInvalidRequestData
+	}
+
+	// cities are stored in lower case so they can be matched against the cities of the trips
+	entry.City = strings.ToLower(strings.TrimSpace(entry.City))
+	if len(entry.City) > maxCityLength {
+		return errorInvalidRequestData
+	}
+
+	if entry.Priority == 0 {
+		entry.Priority = bucketPriorityMedium
+	}
+
+	if _, ok := bucketPriorities[entry.Priority]; !ok {
+		return errorInvalidRequestData
+	}
+
+	entry.Notes = strings.TrimSpace(entry.Notes)
+	if len(entry.Notes) > maxBucketNotesLength {
+		return errorInvalidRequestData
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+
+	"memtravel/currency"
+	"memtravel/db"
+	"memtravel/middleware"
+)
+
+type (
+	// TripBudget is the blueprint for the spending of a trip converted to the currency of the user's country,
+	// Unconverted lists the currencies that had no exchange rate for the day of one of their expenses
+	TripBudget struct {
+		Currency    string             `json:"currency"`
+		Total       int64              `json:"total"`
+		Share       int64              `json:"share"`
+		Spending    []CurrencySpending `json:"spending"`
+		Unconverted []string           `json:"unconverted,omitempty"`
+	}
+
+	// CurrencySpending is the blueprint for how much was spent in a single currency and its converted value
+	CurrencySpending struct {
+		Currency  string `json:"currency"`
+		Amount    int64  `json:"amount"`
+		Converted int64  `json:"converted"`
+	}
+)
+
+func (handler *Handler) GetTripBudgetHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.participantTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	homeCurrency, deferredErr := handler.userCurrency(userID)
+	if deferredErr != nil {
+		return
+	}
+
+	tripExpenses, deferredErr := handler.tripExpenses(tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	trip, deferredErr := handler.getTrip(tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	rates, deferredErr := handler.exchangeRates(homeCurrency, trip.StartDate, tripExpenses)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, tripBudget(fmt.Sprint(userID), homeCurrency, tripExpenses, rates))
+}
+
+// userCurrency returns the currency of the home country of the user, users without a country
+// or whose country has no currency get their totals in currency.Base
+func (handler *Handler) userCurrency(userID any) (string, error) {
+	var code sql.NullString
+
+	err := handler.database.QueryRow(db.GetUserCurrency, userID).Scan(&code)
+	if err == sql.ErrNoRows || (err == nil && !code.Valid) {
+		return currency.Base, nil
+	}
+
+	return code.String, err
+}
+
+// exchangeRates loads the rates needed to convert the expenses into the home currency, from the last rate
+// published before the trip started, or before the first expense when it was paid earlier, up to the last expense
+func (handler *Handler) exchangeRates(homeCurrency string, startDate string, tripExpenses []Expense) (*currency.Table, error) {
+	codes := []string{homeCurrency}
+	latest := time.Time{}
+
+	earliest, err := time.Parse(time.DateOnly, startDate)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, expense := range tripExpenses {
+		codes = append(codes, expense.Currency)
+
+		day, err := time.Parse(time.DateOnly, expense.Date)
+		if err != nil {
+			return nil, err
+		}
+
+		if day.After(latest) {
+			latest = day
+		}
+
+		if day.Before(earliest) {
+			earliest = day
+		}
+	}
+
+	rows, err := handler.database.Query(db.GetExchangeRates, pq.Array(codes), latest, earliest)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var rates []currency.Rate
+
+	for rows.Next() {
+		var rate currency.Rate
+
+		err = rows.Scan(&rate.Day, &rate.Currency, &rate.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		rates = append(rates, rate)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return currency.NewTable(rates)
+}
+
+// tripBudget converts every expense with the rate of the day it was spent, expenses without a rate are left out of the totals
+func tripBudget(userID string, homeCurrency string, tripExpenses []Expense, rates *currency.Table) TripBudget {
+	budget := TripBudget{
+		Currency: homeCurrency,
+		Spending: []CurrencySpending{},
+	}
+
+	spending := make(map[string]*CurrencySpending)
+	unconverted := make(map[string]struct{})
+
+	for _, expense := range tripExpenses {
+		if spending[expense.Currency] == nil {
+			spending[expense.Currency] = &CurrencySpending{Currency: expense.Currency}
+		}
+
+		spending[expense.Currency].Amount += expense.Amount
+
+		day, _ := time.Parse(time.DateOnly, expense.Date)
+
+		converted, err := rates.Convert(expense.Amount, expense.Currency, homeCurrency, day)
+		if err != nil {
+			unconverted[expense.Currency] = struct{}{}
+			continue
+		}
+
+		spending[expense.Currency].Converted += converted
+		budget.Total += converted
+
+		for _, share := range expense.Participants {
+			if strconv.Itoa(share.UserID) != userID {
+				continue
+			}
+
+			convertedShare, err := rates.Convert(share.Amount, expense.Currency, homeCurrency, day)
+			if err == nil {
+				budget.Share += convertedShare
+			}
+		}
+	}
+
+	for _, currencySpending := range spending {
+		budget.Spending = append(budget.Spending, *currencySpending)
+	}
+
+	sort.Slice(budget.Spending, func(i, j int) bool { return budget.Spending[i].Currency < budget.Spending[j].Currency })
+
+	for code := range unconverted {
+		budget.Unconverted = append(budget.Unconverted, code)
+	}
+
+	sort.Strings(budget.Unconverted)
+
+	return budget
+}
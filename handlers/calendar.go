@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"memtravel/configs"
+	"memtravel/db"
+	"memtravel/ical"
+	"memtravel/middleware"
+)
+
+// CalendarSubscription is the blueprint for the secret url calendar apps use to subscribe to the trips of a user
+type CalendarSubscription struct {
+	URL string `json:"url"`
+}
+
+const (
+	calendarPath = "/calendar/"
+)
+
+func (handler *Handler) TripsCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	deferredErr = handler.writeCalendar(w, r, userID)
+}
+
+// CalendarFeedHandler serves the calendar of the owner of the token, it does not need the Authorization header
+// so calendar apps can subscribe to it
+func (handler *Handler) CalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	token := strings.TrimSuffix(r.PathValue(calendarParamID), ".ics")
+
+	var userID int
+
+	deferredErr = handler.database.QueryRow(db.GetCalendarTokenUser, hashSecretToken(token)).Scan(&userID)
+	if deferredErr == sql.ErrNoRows {
+		deferredErr = nil
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.writeCalendar(w, r, userID)
+}
+
+func (handler *Handler) RegenerateCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	token, deferredErr := newSecretToken()
+	if deferredErr != nil {
+		return
+	}
+
+	// replacing the hash revokes the previous url
+	deferredErr = handler.database.ExecQuery(db.UpsertCalendarToken, userID, hashSecretToken(token))
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, CalendarSubscription{URL: configs.Envs.BaseURL + calendarPath + token + ".ics"})
+}
+
+func (handler *Handler) RevokeCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	deferredErr = handler.database.ExecQuery(db.RemoveCalendarToken, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, "")
+}
+
+// writeCalendar writes every trip of the user as an all-day event, when the legs query param is true
+// trips that have legs are written as one event per leg instead
+func (handler *Handler) writeCalendar(w http.ResponseWriter, r *http.Request, userID any) error {
+	perLeg, _ := strconv.ParseBool(r.URL.Query().Get(legsParamID))
+
+	events, err := handler.calendarEvents(userID, perLeg)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+
+	err = ical.Encode(&buf, ical.Calendar{Name: "memtravel", Events: events}, time.Now())
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", ical.ContentType)
+	w.Header().Set("Content-Disposition", `inline; filename="memtravel.ics"`)
+	w.Header().Set("Cache-Control", "private, max-age=900")
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// calendarEvents reads the trips of the user and turns them into calendar events
+func (handler *Handler) calendarEvents(userID any, perLeg bool) ([]ical.Event, error) {
+	legEvents := make(map[int][]ical.Event)
+
+	if perLeg {
+		rows, err := handler.database.Query(db.GetCalendarLegs, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var legID, tripID int
+			var title, city string
+			var event ical.Event
+
+			err = rows.Scan(&legID, &tripID, &title, &city, &event.Start, &event.End)
+			if err != nil {
+				return nil, err
+			}
+
+			event.UID = fmt.Sprintf("leg-%d@memtravel", legID)
+			event.Summary = fmt.Sprintf("%s: %s", title, city)
+			event.Location = city
+
+			legEvents[tripID] = append(legEvents[tripID], event)
+		}
+
+		err = rows.Err()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := handler.database.Query(db.GetCalendarTrips, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	events := []ical.Event{}
+
+	for rows.Next() {
+		var tripID int
+		var cities []string
+		var event ical.Event
+
+		err = rows.Scan(&tripID, &event.Summary, pq.Array(&cities), &event.Start, &event.End, &event.Description)
+		if err != nil {
+			return nil, err
+		}
+
+		if legs, ok := legEvents[tripID]; ok {
+			events = append(events, legs...)
+			continue
+		}
+
+		event.UID = fmt.Sprintf("trip-%d@memtravel", tripID)
+		event.Location = strings.Join(cities, ", ")
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,610 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"memtravel/db"
+	"memtravel/middleware"
+)
+
+type (
+	// Checklist is the blueprint for a list of things to do or pack for a trip
+	Checklist struct {
+		ChecklistID int             `json:"checklistid,omitempty"`
+		Kind        string          `json:"kind"`
+		Title       string          `json:"title"`
+		Items       []ChecklistItem `json:"items"`
+		TemplateID  int             `json:"templateid,omitempty"`
+	}
+
+	// ChecklistItem is the blueprint for a single entry of a checklist, AssigneeID is 0 when nobody is assigned
+	ChecklistItem struct {
+		ItemID     int    `json:"itemid,omitempty"`
+		Text       string `json:"text"`
+		Checked    bool   `json:"checked"`
+		AssigneeID int    `json:"assigneeid,omitempty"`
+	}
+
+	// ChecklistTemplate is the blueprint for a reusable checklist owned by a user
+	ChecklistTemplate struct {
+		TemplateID int      `json:"templateid,omitempty"`
+		Kind       string   `json:"kind"`
+		Title      string   `json:"title"`
+		Items      []string `json:"items"`
+	}
+
+	// ItemCheck is the blueprint for the request that checks or unchecks an item
+	ItemCheck struct {
+		Checked bool `json:"checked"`
+	}
+)
+
+const (
+	checklistKindPacking   = "packing"
+	checklistKindDocuments = "documents"
+	checklistKindBookings  = "bookings"
+
+	maxChecklistTitleLength = 60
+	maxChecklistItems       = 200
+	maxChecklistItemLength  = 200
+)
+
+var checklistKinds = map[string]struct{}{
+	checklistKindPacking:   {},
+	checklistKindDocuments: {},
+	checklistKindBookings:  {},
+}
+
+func (handler *Handler) GetChecklistsHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.participantTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	checklists, deferredErr := handler.tripChecklists(tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, checklists)
+}
+
+func (handler *Handler) AddChecklistHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.ownedTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	var checklist Checklist
+
+	deferredErr = readBody(r, &checklist)
+	if deferredErr != nil {
+		return
+	}
+
+	items := make([]string, len(checklist.Items))
+	for i, item := range checklist.Items {
+		items[i] = item.Text
+	}
+
+	// a checklist created from a template takes its kind and items, the title can still be changed
+	if checklist.TemplateID > 0 {
+		var template ChecklistTemplate
+
+		deferredErr = handler.database.QueryRow(db.GetChecklistTemplate, checklist.TemplateID, userID).
+			Scan(&template.Kind, &template.Title, pq.Array(&items))
+		if deferredErr != nil {
+			return
+		}
+
+		checklist.Kind = template.Kind
+		if strings.TrimSpace(checklist.Title) == "" {
+			checklist.Title = template.Title
+		}
+	}
+
+	template := ChecklistTemplate{Kind: checklist.Kind, Title: checklist.Title, Items: items}
+
+	deferredErr = validateChecklistTemplate(&template)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.database.QueryRow(db.AddChecklist, tripID, template.Kind, template.Title, pq.Array(template.Items)).
+		Scan(&checklist.ChecklistID)
+	if deferredErr != nil {
+		return
+	}
+
+	checklists, deferredErr := handler.tripChecklists(tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, checklists)
+}
+
+func (handler *Handler) RemoveChecklistHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.ownedTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	checklistID, deferredErr := strconv.Atoi(r.PathValue(checklistParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.database.ExecQuery(db.RemoveChecklist, checklistID, tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, "")
+}
+
+func (handler *Handler) AddChecklistItemHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.ownedTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	checklistID, deferredErr := strconv.Atoi(r.PathValue(checklistParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	var item ChecklistItem
+
+	deferredErr = readBody(r, &item)
+	if deferredErr != nil {
+		return
+	}
+
+	item.Text = strings.TrimSpace(item.Text)
+	if item.Text == "" || len(item.Text) > maxChecklistItemLength {
+		deferredErr = errorInvalidRequestData
+		return
+	}
+
+	assignee, deferredErr := handler.checklistAssignee(tripID, item.AssigneeID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.database.ExecQuery(db.AddChecklistItem, checklistID, tripID, item.Text, assignee)
+	if deferredErr != nil {
+		return
+	}
+
+	checklists, deferredErr := handler.tripChecklists(tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, checklists)
+}
+
+func (handler *Handler) RemoveChecklistItemHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.ownedTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	checklistID, itemID, deferredErr := checklistItemIDs(r)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.database.ExecQuery(db.RemoveChecklistItem, itemID, checklistID, tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, "")
+}
+
+func (handler *Handler) CheckChecklistItemHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	// every participant of a group trip can check items off
+	tripID, deferredErr := handler.participantTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	checklistID, itemID, deferredErr := checklistItemIDs(r)
+	if deferredErr != nil {
+		return
+	}
+
+	var check ItemCheck
+
+	deferredErr = readBody(r, &check)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.database.ExecQuery(db.CheckChecklistItem, check.Checked, itemID, checklistID, tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, "")
+}
+
+func (handler *Handler) AssignChecklistItemHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.ownedTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	checklistID, itemID, deferredErr := checklistItemIDs(r)
+	if deferredErr != nil {
+		return
+	}
+
+	// an empty friend query param removes the assignee
+	var assigneeID int
+
+	if friendParam := r.URL.Query().Get(friendParamID); friendParam != "" {
+		assigneeID, deferredErr = strconv.Atoi(friendParam)
+		if deferredErr != nil {
+			return
+		}
+	}
+
+	assignee, deferredErr := handler.checklistAssignee(tripID, assigneeID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.database.ExecQuery(db.AssignChecklistItem, assignee, itemID, checklistID, tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, "")
+}
+
+func (handler *Handler) GetChecklistTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	rows, deferredErr := handler.database.Query(db.GetChecklistTemplates, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	templates := []ChecklistTemplate{}
+
+	for rows.Next() {
+		var template ChecklistTemplate
+
+		deferredErr = rows.Scan(&template.TemplateID, &template.Kind, &template.Title, pq.Array(&template.Items))
+		if deferredErr != nil {
+			return
+		}
+
+		templates = append(templates, template)
+	}
+
+	deferredErr = rows.Err()
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, templates)
+}
+
+func (handler *Handler) AddChecklistTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	var template ChecklistTemplate
+
+	deferredErr = readBody(r, &template)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = validateChecklistTemplate(&template)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.database.QueryRow(db.AddChecklistTemplate, userID, template.Kind, template.Title, pq.Array(template.Items)).
+		Scan(&template.TemplateID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, template)
+}
+
+func (handler *Handler) RemoveChecklistTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	templateID, deferredErr := strconv.Atoi(r.PathValue(templateParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.database.ExecQuery(db.RemoveChecklistTemplate, templateID, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, "")
+}
+
+// tripChecklists reads every checklist of a trip with its items in order
+func (handler *Handler) tripChecklists(tripID int) ([]Checklist, error) {
+	rows, err := handler.database.Query(db.GetTripChecklists, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	checklists := []Checklist{}
+	positions := make(map[int]int)
+
+	for rows.Next() {
+		checklist := Checklist{
+			Items: []ChecklistItem{},
+		}
+
+		err = rows.Scan(&checklist.ChecklistID, &checklist.Kind, &checklist.Title)
+		if err != nil {
+			return nil, err
+		}
+
+		positions[checklist.ChecklistID] = len(checklists)
+		checklists = append(checklists, checklist)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	itemRows, err := handler.database.Query(db.GetTripChecklistItems, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var item ChecklistItem
+		var checklistID int
+		var assigneeID sql.NullInt64
+
+		err = itemRows.Scan(&item.ItemID, &checklistID, &item.Text, &item.Checked, &assigneeID)
+		if err != nil {
+			return nil, err
+		}
+
+		item.AssigneeID = int(assigneeID.Int64)
+
+		if position, ok := positions[checklistID]; ok {
+			checklists[position].Items = append(checklists[position].Items, item)
+		}
+	}
+
+	return checklists, itemRows.Err()
+}
+
+// checklistAssignee makes sure the assignee is part of the trip, 0 means nobody and is stored as null
+func (handler *Handler) checklistAssignee(tripID int, assigneeID int) (sql.NullInt64, error) {
+	if assigneeID == 0 {
+		return sql.NullInt64{}, nil
+	}
+
+	var participants int
+
+	err := handler.database.QueryRow(db.CountTripParticipants, tripID, pq.Array([]int64{int64(assigneeID)})).Scan(&participants)
+	if err != nil {
+		return sql.NullInt64{}, err
+	}
+
+	if participants != 1 {
+		return sql.NullInt64{}, errorNotTripParticipant
+	}
+
+	return sql.NullInt64{Int64: int64(assigneeID), Valid: true}, nil
+}
+
+// checklistItemIDs reads the checklist and item ids from the path
+func checklistItemIDs(r *http.Request) (int, int, error) {
+	checklistID, err := strconv.Atoi(r.PathValue(checklistParamID))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	itemID, err := strconv.Atoi(r.PathValue(itemParamID))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return checklistID, itemID, nil
+}
+
+// validateChecklistTemplate checks the kind, title and items shared by checklists and templates
+func validateChecklistTemplate(template *ChecklistTemplate) error {
+	if _, ok := checklistKinds[template.Kind]; !ok {
+		return fmt.Errorf("%s is not a valid checklist kind", template.Kind)
+	}
+
+	template.Title = strings.TrimSpace(template.Title)
+	if template.Title == "" || len(template.Title) > maxChecklistTitleLength {
+		return errorInvalidRequestData
+	}
+
+	if len(template.Items) > maxChecklistItems {
+		return errorInvalidRequestData
+	}
+
+	for i, item := range template.Items {
+		template.Items[i] = strings.TrimSpace(item)
+		if template.Items[i] == "" || len(template.Items[i]) > maxChecklistItemLength {
+			return errorInvalidRequestData
+		}
+	}
+
+	if template.Items == nil {
+		template.Items = []string{}
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var errorInvalidCursor = errors.New("invalid pagination cursor")
+
+// encodeCursor creates an opaque pagination cursor out of the sort key and the id of the last row sent
+func encodeCursor(key string, id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key + "|" + strconv.Itoa(id)))
+}
+
+// decodeCursor reads back the sort key and id stored in a cursor created by encodeCursor
+func decodeCursor(cursor string) (string, int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, errorInvalidCursor
+	}
+
+	key, id, found := strings.Cut(string(decoded), "|")
+	if !found || key == "" {
+		return "", 0, errorInvalidCursor
+	}
+
+	idInt, err := strconv.Atoi(id)
+	if err != nil {
+		return "", 0, errorInvalidCursor
+	}
+
+	return key, idInt, nil
+}
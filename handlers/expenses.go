@@ -0,0 +1,404 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"memtravel/currency"
+	"memtravel/db"
+	"memtravel/expenses"
+	"memtravel/middleware"
+)
+
+type (
+	// Expense is the blueprint for an expense paid by a trip participant and shared with others
+	Expense struct {
+		ExpenseID    int            `json:"expenseid,omitempty"`
+		PayerID      int            `json:"payerid"`
+		Amount       int64          `json:"amount"`
+		Currency     string         `json:"currency"`
+		Description  string         `json:"description"`
+		SplitMode    string         `json:"splitmode"`
+		Date         string         `json:"date"`
+		Participants []ExpenseShare `json:"participants"`
+	}
+
+	// ExpenseShare is the blueprint for a participant of an expense, Share is the weight or exact amount
+	// sent when the expense is created and Amount is what the participant owes once the expense is split
+	ExpenseShare struct {
+		UserID int   `json:"userid"`
+		Share  int64 `json:"share,omitempty"`
+		Amount int64 `json:"amount"`
+	}
+
+	// CurrencyBalances is the blueprint for the balances of the trip participants in a single currency
+	// and the transfers needed to settle them
+	CurrencyBalances struct {
+		Currency    string          `json:"currency"`
+		Balances    []MemberBalance `json:"balances"`
+		Settlements []Settlement    `json:"settlements"`
+	}
+
+	// MemberBalance is the blueprint for how much a participant paid and owes, a positive balance is owed to the participant
+	MemberBalance struct {
+		UserID  int   `json:"userid"`
+		Paid    int64 `json:"paid"`
+		Owed    int64 `json:"owed"`
+		Balance int64 `json:"balance"`
+	}
+
+	// Settlement is the blueprint for a payment that settles part of the balances
+	Settlement struct {
+		From   int   `json:"from"`
+		To     int   `json:"to"`
+		Amount int64 `json:"amount"`
+	}
+)
+
+const (
+	maxExpenseDescriptionLength = 100
+)
+
+var (
+	errorPayerNotAllowed = errors.New("only the trip owner can add expenses paid by someone else")
+)
+
+func (handler *Handler) GetExpensesHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.participantTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	tripExpenses, deferredErr := handler.tripExpenses(tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, tripExpenses)
+}
+
+func (handler *Handler) AddExpenseHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.participantTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	var expense Expense
+
+	deferredErr = readBody(r, &expense)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = validateExpense(&expense)
+	if deferredErr != nil {
+		return
+	}
+
+	// only the owner of the trip can record what someone else paid, or anyone could create debts for others
+	if strconv.Itoa(expense.PayerID) != fmt.Sprint(userID) {
+		owner, err := handler.tripBelongsToUser(userID, tripID)
+		if err != nil {
+			deferredErr = err
+			return
+		}
+
+		if !owner {
+			deferredErr = errorPayerNotAllowed
+			return
+		}
+	}
+
+	shares := make([]expenses.Share, len(expense.Participants))
+	for i, participant := range expense.Participants {
+		shares[i] = expenses.Share{UserID: participant.UserID, Value: participant.Share}
+	}
+
+	owed, deferredErr := expenses.Split(expense.Amount, expense.SplitMode, shares)
+	if deferredErr != nil {
+		return
+	}
+
+	// the payer and every participant must be part of the trip
+	userIDs := []int64{int64(expense.PayerID)}
+	shareUserIDs := make([]int64, len(owed))
+	shareAmounts := make([]int64, len(owed))
+
+	for i, share := range owed {
+		shareUserIDs[i] = int64(share.UserID)
+		shareAmounts[i] = share.Value
+
+		if share.UserID != expense.PayerID {
+			userIDs = append(userIDs, int64(share.UserID))
+		}
+	}
+
+	var participants int
+
+	deferredErr = handler.database.QueryRow(db.CountTripParticipants, tripID, pq.Array(userIDs)).Scan(&participants)
+	if deferredErr != nil {
+		return
+	}
+
+	if participants != len(userIDs) {
+		deferredErr = errorNotTripParticipant
+		return
+	}
+
+	deferredErr = handler.database.QueryRow(
+		db.AddExpense,
+		tripID,
+		expense.PayerID,
+		expense.Amount,
+		expense.Currency,
+		expense.Description,
+		expense.SplitMode,
+		expense.Date,
+		pq.Array(shareUserIDs),
+		pq.Array(shareAmounts),
+	).Scan(&expense.ExpenseID)
+	if deferredErr != nil {
+		return
+	}
+
+	expense.Participants = make([]ExpenseShare, len(owed))
+	for i, share := range owed {
+		expense.Participants[i] = ExpenseShare{UserID: share.UserID, Amount: share.Value}
+	}
+
+	deferredErr = writeServerResponse(w, true, expense)
+}
+
+func (handler *Handler) RemoveExpenseHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.participantTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	expenseID, deferredErr := strconv.Atoi(r.PathValue(expenseParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	// only the payer or the trip owner can remove an expense
+	deferredErr = handler.database.ExecQuery(db.RemoveExpense, expenseID, tripID, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, "")
+}
+
+func (handler *Handler) GetExpenseBalancesHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.participantTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	tripExpenses, deferredErr := handler.tripExpenses(tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, expenseBalances(tripExpenses))
+}
+
+// tripExpenses reads every expense of a trip with the amount owed by each participant
+func (handler *Handler) tripExpenses(tripID int) ([]Expense, error) {
+	rows, err := handler.database.Query(db.GetTripExpenses, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tripExpenses := []Expense{}
+
+	for rows.Next() {
+		var expense Expense
+		var share ExpenseShare
+		var spentAt time.Time
+
+		err = rows.Scan(
+			&expense.ExpenseID,
+			&expense.PayerID,
+			&expense.Amount,
+			&expense.Currency,
+			&expense.Description,
+			&expense.SplitMode,
+			&spentAt,
+			&share.UserID,
+			&share.Amount,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// rows are sorted by expense so the shares of the same expense are always next to each other
+		last := len(tripExpenses) - 1
+		if last >= 0 && tripExpenses[last].ExpenseID == expense.ExpenseID {
+			tripExpenses[last].Participants = append(tripExpenses[last].Participants, share)
+			continue
+		}
+
+		expense.Date = spentAt.Format(time.DateOnly)
+		expense.Participants = []ExpenseShare{share}
+
+		tripExpenses = append(tripExpenses, expense)
+	}
+
+	return tripExpenses, rows.Err()
+}
+
+// expenseBalances computes how much each participant paid and owes per currency and the transfers that settle them
+func expenseBalances(tripExpenses []Expense) []CurrencyBalances {
+	byCurrency := make(map[string]map[int]*MemberBalance)
+
+	member := func(currency string, userID int) *MemberBalance {
+		if byCurrency[currency] == nil {
+			byCurrency[currency] = make(map[int]*MemberBalance)
+		}
+
+		if byCurrency[currency][userID] == nil {
+			byCurrency[currency][userID] = &MemberBalance{UserID: userID}
+		}
+
+		return byCurrency[currency][userID]
+	}
+
+	for _, expense := range tripExpenses {
+		member(expense.Currency, expense.PayerID).Paid += expense.Amount
+
+		for _, share := range expense.Participants {
+			member(expense.Currency, share.UserID).Owed += share.Amount
+		}
+	}
+
+	balances := []CurrencyBalances{}
+
+	for currency, members := range byCurrency {
+		currencyBalances := CurrencyBalances{
+			Currency:    currency,
+			Settlements: []Settlement{},
+		}
+
+		settle := make(map[int]int64, len(members))
+
+		for userID, balance := range members {
+			balance.Balance = balance.Paid - balance.Owed
+			settle[userID] = balance.Balance
+
+			currencyBalances.Balances = append(currencyBalances.Balances, *balance)
+		}
+
+		sort.Slice(currencyBalances.Balances, func(i, j int) bool {
+			return currencyBalances.Balances[i].UserID < currencyBalances.Balances[j].UserID
+		})
+
+		for _, transfer := range expenses.Settle(settle) {
+			currencyBalances.Settlements = append(currencyBalances.Settlements, Settlement(transfer))
+		}
+
+		balances = append(balances, currencyBalances)
+	}
+
+	sort.Slice(balances, func(i, j int) bool { return balances[i].Currency < balances[j].Currency })
+
+	return balances
+}
+
+// validateExpense checks the expense request data, the split itself is validated by expenses.Split
+func validateExpense(expense *Expense) error {
+	expense.Description = strings.TrimSpace(expense.Description)
+	if expense.Description == "" || len(expense.Description) > maxExpenseDescriptionLength {
+		return errorInvalidRequestData
+	}
+
+	if expense.PayerID <= 0 {
+		return errorInvalidRequestData
+	}
+
+	expense.Currency = strings.ToUpper(strings.TrimSpace(expense.Currency))
+	if !currency.Valid(expense.Currency) {
+		return errorInvalidRequestData
+	}
+
+	if expense.Date == "" {
+		expense.Date = time.Now().Format(time.DateOnly)
+	}
+
+	_, err := time.Parse(time.DateOnly, expense.Date)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -9,6 +9,27 @@ import (
 	"memtravel/middleware"
 	"net/http"
 	"strconv"
+	"time"
+)
+
+type (
+	// FriendRequest is the blueprint for a pending friend request, User is the card of the other side of the request
+	FriendRequest struct {
+		User User   `json:"user"`
+		Sent string `json:"sent"`
+	}
+
+	// FriendRequestPage holds a page of friend requests and the cursor to request the next one
+	FriendRequestPage struct {
+		Requests []FriendRequest `json:"requests"`
+		Cursor   string          `json:"cursor,omitempty"`
+	}
+
+	// FriendRequestCount is the blueprint for the number of pending friend requests the user received and sent
+	FriendRequestCount struct {
+		Incoming int `json:"incoming"`
+		Outgoing int `json:"outgoing"`
+	}
 )
 
 const (
@@ -16,6 +37,7 @@ const (
 	acceptFriendRequest  = "accept"
 	removeFriendRequest  = "remove"
 	addNewFriendRequest  = "add"
+	friendRequestsLimit  = 20
 )
 
 var handlerTypes = map[string]struct{}{
@@ -127,6 +149,12 @@ func (handler *Handler) RemoveFriendHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// neither of them can be a close friend of the other once they are not friends
+	_, deferredErr = handler.database.Exec(db.RemoveCloseFriends, userID, friendID)
+	if deferredErr != nil {
+		return
+	}
+
 	deferredErr = writeServerResponse(w, true, "")
 }
 
@@ -173,3 +201,261 @@ func (handler *Handler) GetFriendsHandler(w http.ResponseWriter, r *http.Request
 
 	deferredErr = writeServerResponse(w, true, friends)
 }
+
+func (handler *Handler) GetCloseFriendsHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	closeFriends := []User{}
+
+	rows, deferredErr := handler.database.Query(db.GetCloseFriends, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var friend User
+
+		deferredErr = rows.Scan(&friend.UserID, &friend.FullName, &friend.ProfilePicture)
+		if deferredErr != nil {
+			return
+		}
+
+		closeFriends = append(closeFriends, friend)
+	}
+
+	deferredErr = rows.Err()
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, closeFriends)
+}
+
+func (handler *Handler) AddCloseFriendHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	friendID, deferredErr := strconv.Atoi(r.URL.Query().Get(friendParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	friend, deferredErr := handler.isFriend(userID, friendID)
+	if deferredErr != nil {
+		return
+	}
+
+	if !friend {
+		deferredErr = fmt.Errorf("%d is not a friend of the user", friendID)
+		return
+	}
+
+	// adding someone that already is a close friend is not an error
+	_, deferredErr = handler.database.Exec(db.AddCloseFriend, userID, friendID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, "")
+}
+
+func (handler *Handler) RemoveCloseFriendHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	friendID, deferredErr := strconv.Atoi(r.URL.Query().Get(friendParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.database.ExecQuery(db.RemoveCloseFriend, userID, friendID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, "")
+}
+
+// isFriend checks if both users are friends
+func (handler *Handler) isFriend(userID any, otherID int) (bool, error) {
+	rows, err := handler.database.Query(db.CheckIfUserHasFriend, userID, otherID)
+	if err != nil {
+		return false, err
+	}
+
+	defer rows.Close()
+
+	return rows.Next(), rows.Err()
+}
+
+func (handler *Handler) GetIncomingFriendRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	page, deferredErr := handler.friendRequests(r, db.GetIncomingFriendRequests, db.GetIncomingFriendRequestsNext)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, page)
+}
+
+func (handler *Handler) GetOutgoingFriendRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	page, deferredErr := handler.friendRequests(r, db.GetOutgoingFriendRequests, db.GetOutgoingFriendRequestsNext)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, page)
+}
+
+func (handler *Handler) CountFriendRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	var count FriendRequestCount
+
+	deferredErr = handler.database.QueryRow(db.CountFriendRequests, userID).Scan(&count.Incoming, &count.Outgoing)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, count)
+}
+
+// friendRequests reads a page of the pending friend requests of the user, newest first.
+// firstPageQuery is used when no cursor is sent, nextPageQuery continues after the (created, userid) in the cursor
+func (handler *Handler) friendRequests(r *http.Request, firstPageQuery string, nextPageQuery string) (FriendRequestPage, error) {
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	var rows *sql.Rows
+	var err error
+
+	cursor := r.URL.Query().Get(cursorParamID)
+	if cursor == "" {
+		rows, err = handler.database.Query(firstPageQuery, userID, friendRequestsLimit)
+	} else {
+		cursorSent, cursorUserID, cursorErr := decodeCursor(cursor)
+		if cursorErr != nil {
+			return FriendRequestPage{}, cursorErr
+		}
+
+		sent, parseErr := time.Parse(time.RFC3339Nano, cursorSent)
+		if parseErr != nil {
+			return FriendRequestPage{}, errorInvalidCursor
+		}
+
+		rows, err = handler.database.Query(nextPageQuery, userID, friendRequestsLimit, sent, cursorUserID)
+	}
+
+	if err != nil {
+		return FriendRequestPage{}, err
+	}
+
+	defer rows.Close()
+
+	page := FriendRequestPage{
+		Requests: []FriendRequest{},
+	}
+
+	for rows.Next() {
+		var request FriendRequest
+		var sent time.Time
+
+		err = rows.Scan(&request.User.UserID, &request.User.FullName, &request.User.Username, &request.User.ProfilePicture, &sent)
+		if err != nil {
+			return FriendRequestPage{}, err
+		}
+
+		request.User.ProfilePicture = mediaURL(request.User.ProfilePicture)
+		request.Sent = sent.Format(time.RFC3339Nano)
+
+		page.Requests = append(page.Requests, request)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return FriendRequestPage{}, err
+	}
+
+	if len(page.Requests) == friendRequestsLimit {
+		last := page.Requests[len(page.Requests)-1]
+		page.Cursor = encodeCursor(last.Sent, last.User.UserID)
+	}
+
+	return page, nil
+}
@@ -2,14 +2,21 @@ package handlers
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"html/template"
 	"net/http"
 	"net/smtp"
+	"time"
 
 	"memtravel/configs"
 	"memtravel/db"
+	"memtravel/emissions"
+	"memtravel/geo"
+	"memtravel/media"
 )
 
 type (
@@ -32,6 +39,7 @@ type (
 		Bio            string       `json:"bio,omitempty"`
 		PinnedTrips    []PinnedTrip `json:"pinned,omitempty"`
 		Stats          []Stats      `json:"stats,omitempty"`
+		Badges         []Badge      `json:"badges,omitempty"`
 		LoginAttempt   int          `json:"loginattempt,omitempty"`
 		AccountCreated bool         `json:"accountcreated,omitempty"`
 	}
@@ -44,8 +52,11 @@ type (
 
 	// Handler object that holds all needed attributes for the handlers
 	Handler struct {
-		database db.Database
-		tmpl     *template.Template
+		database   db.Database
+		tmpl       *template.Template
+		storage    media.Storage
+		boundaries *geo.Boundaries
+		emissions  emissions.Factors
 	}
 )
 
@@ -56,10 +67,34 @@ const (
 	friendRequestParamID string = "type"
 	friendParamID        string = "friend"
 	privacyParamID       string = "pid"
+	visibilityParamID    string = "vid"
 	tripParamID          string = "tpid"
 	countryParamID       string = "cid"
+	legParamID           string = "lgid"
+	mediaParamID         string = "mid"
+	mediaKeyParamID      string = "key"
+	entryParamID         string = "eid"
+	responseParamID      string = "response"
+	voteKindParamID      string = "kind"
+	expenseParamID       string = "exid"
+	checklistParamID     string = "clid"
+	itemParamID          string = "ciid"
+	templateParamID      string = "ctid"
+	calendarParamID      string = "token"
+	legsParamID          string = "legs"
+	formatParamID        string = "format"
+	publicParamID        string = "public"
+	cursorParamID        string = "cursor"
+	userParamID          string = "user"
+	usernameParamID      string = "username"
+	timezoneParamID      string = "tz"
+	shareParamID         string = "sid"
+	shareTokenParamID    string = "token"
 )
 
+// secretTokenLength is the number of random bytes of calendar and share tokens
+const secretTokenLength = 32
+
 var (
 	errorLanguageID         = errors.New("languageID is not supported")
 	errorPathValueNotFound  = errors.New("path value not found")
@@ -67,10 +102,13 @@ var (
 )
 
 // NewHandler creates a new object
-func NewHandler(db db.Database, tmpl *template.Template) *Handler {
+func NewHandler(db db.Database, tmpl *template.Template, storage media.Storage, boundaries *geo.Boundaries, factors emissions.Factors) *Handler {
 	return &Handler{
-		database: db,
-		tmpl:     tmpl,
+		database:   db,
+		tmpl:       tmpl,
+		storage:    storage,
+		boundaries: boundaries,
+		emissions:  factors,
 	}
 }
 
@@ -114,3 +152,29 @@ func sendEmail(sendTo []string, emailType string, subject string, context any, t
 
 	return smtp.SendMail(configs.Envs.SMTPHost+":"+configs.Envs.SMTPPort, auth, configs.Envs.EmailFrom, sendTo, body.Bytes())
 }
+
+// newSecretToken creates a random url safe token for links that work without the Authorization header
+func newSecretToken() (string, error) {
+	token := make([]byte, secretTokenLength)
+
+	_, err := rand.Read(token)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(token), nil
+}
+
+// hashSecretToken returns the value stored in the database for the token, so a leaked database does not expose the links
+func hashSecretToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// untilMidnight caps how long a value computed against the current date is cached, so it expires when the day changes
+func untilMidnight(maximum time.Duration) time.Duration {
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+
+	return min(maximum, midnight.Sub(now))
+}
@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"memtravel/db"
+	"memtravel/geo"
+	"memtravel/middleware"
+)
+
+type (
+	// TripDraft is the blueprint for a trip created from an imported track that the user still has to confirm
+	TripDraft struct {
+		DraftID   int    `json:"draftid"`
+		Title     string `json:"title"`
+		Country   int    `json:"country"`
+		StartDate string `json:"startdate"`
+		EndDate   string `json:"enddate"`
+		Legs      []Leg  `json:"legs"`
+	}
+
+	// DraftConfirm is the blueprint for the confirm draft request, an empty title keeps the imported one
+	DraftConfirm struct {
+		Title      string `json:"title"`
+		Visibility int    `json:"visibility"`
+	}
+)
+
+const (
+	defaultImportTitle = "Imported trip"
+	maxImportedLegs    = 100
+)
+
+var (
+	errorImportsDisabled = errors.New("country boundaries are not configured")
+	errorNoCountries     = errors.New("imported track did not cross any known country")
+)
+
+func (handler *Handler) ImportTripHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	if handler.boundaries == nil {
+		deferredErr = errorImportsDisabled
+		return
+	}
+
+	data, deferredErr := readUpload(w, r)
+	if deferredErr != nil {
+		return
+	}
+
+	format, deferredErr := geo.DetectFormat(data)
+	if deferredErr != nil {
+		return
+	}
+
+	track, deferredErr := geo.Parse(data, format)
+	if deferredErr != nil {
+		return
+	}
+
+	visits, deferredErr := geo.Visits(track, handler.boundaries)
+	if deferredErr != nil {
+		return
+	}
+
+	draft, deferredErr := handler.draftFromVisits(track.Name, visits)
+	if deferredErr != nil {
+		return
+	}
+
+	legs, deferredErr := json.Marshal(draft.Legs)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.database.QueryRow(
+		db.AddTripDraft,
+		userID,
+		draft.Title,
+		draft.Country,
+		draft.StartDate,
+		draft.EndDate,
+		string(legs),
+	).Scan(&draft.DraftID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, draft)
+}
+
+func (handler *Handler) GetTripDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	rows, deferredErr := handler.database.Query(db.GetTripDrafts, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	drafts := []TripDraft{}
+
+	for rows.Next() {
+		var draft TripDraft
+		var startDate, endDate time.Time
+		var legs []byte
+
+		deferredErr = rows.Scan(&draft.DraftID, &draft.Title, &draft.Country, &startDate, &endDate, &legs)
+		if deferredErr != nil {
+			return
+		}
+
+		deferredErr = json.Unmarshal(legs, &draft.Legs)
+		if deferredErr != nil {
+			return
+		}
+
+		draft.StartDate = startDate.Format(time.DateOnly)
+		draft.EndDate = endDate.Format(time.DateOnly)
+
+		drafts = append(drafts, draft)
+	}
+
+	deferredErr = rows.Err()
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, drafts)
+}
+
+func (handler *Handler) ConfirmTripDraftHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	draftID, deferredErr := strconv.Atoi(r.PathValue(pathParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	var confirm DraftConfirm
+
+	// drafts confirmed without a visibility keep the default visibility of the account
+	deferredErr = handler.database.QueryRow(db.GetTripVisibility, userID).Scan(&confirm.Visibility)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = readBody(r, &confirm)
+	if deferredErr != nil {
+		return
+	}
+
+	confirm.Title = strings.TrimSpace(confirm.Title)
+	if len(confirm.Title) > maxTripTitleLength {
+		deferredErr = errorInvalidRequestData
+		return
+	}
+
+	if _, ok := tripVisibilities[confirm.Visibility]; !ok {
+		deferredErr = errorInvalidRequestData
+		return
+	}
+
+	var tripID int
+
+	// the draft is removed and the trip with its legs created in a single statement
+	deferredErr = handler.database.QueryRow(db.ConfirmTripDraft, draftID, userID, confirm.Title, confirm.Visibility).Scan(&tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	handler.tripsChanged(userID)
+
+	trip, deferredErr := handler.getTrip(tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, trip)
+}
+
+func (handler *Handler) RemoveTripDraftHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	draftID, deferredErr := strconv.Atoi(r.PathValue(pathParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.database.ExecQuery(db.RemoveTripDraft, draftID, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, "")
+}
+
+// draftFromVisits turns the countries crossed by a track into legs, the country of the trip is the one
+// where most time was spent and countries missing from the countries table are skipped
+func (handler *Handler) draftFromVisits(name string, visits []geo.Visit) (TripDraft, error) {
+	codes := make([]string, 0, len(visits))
+	for _, visit := range visits {
+		codes = append(codes, visit.Country)
+	}
+
+	rows, err := handler.database.Query(db.GetCountryIDsByISO, pq.Array(codes))
+	if err != nil {
+		return TripDraft{}, err
+	}
+
+	defer rows.Close()
+
+	countryIDs := make(map[string]int)
+
+	for rows.Next() {
+		var countryID int
+		var iso string
+
+		err = rows.Scan(&countryID, &iso)
+		if err != nil {
+			return TripDraft{}, err
+		}
+
+		countryIDs[iso] = countryID
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return TripDraft{}, err
+	}
+
+	draft := TripDraft{
+		Title: strings.TrimSpace(name),
+		Legs:  []Leg{},
+	}
+
+	if draft.Title == "" || len(draft.Title) > maxTripTitleLength {
+		draft.Title = defaultImportTitle
+	}
+
+	timeSpent := make(map[int]time.Duration)
+	var longest time.Duration
+
+	for _, visit := range visits {
+		countryID, ok := countryIDs[visit.Country]
+		if !ok || len(draft.Legs) == maxImportedLegs {
+			continue
+		}
+
+		leg := Leg{
+			Position:  len(draft.Legs) + 1,
+			Country:   countryID,
+			Arrival:   visit.Arrival.Format(time.DateOnly),
+			Departure: visit.Departure.Format(time.DateOnly),
+			Transport: transportOther,
+		}
+
+		draft.Legs = append(draft.Legs, leg)
+
+		if draft.StartDate == "" || leg.Arrival < draft.StartDate {
+			draft.StartDate = leg.Arrival
+		}
+
+		if leg.Departure > draft.EndDate {
+			draft.EndDate = leg.Departure
+		}
+
+		timeSpent[countryID] += visit.Departure.Sub(visit.Arrival)
+		if draft.Country == 0 || timeSpent[countryID] > longest {
+			draft.Country = countryID
+			longest = timeSpent[countryID]
+		}
+	}
+
+	if len(draft.Legs) == 0 {
+		return TripDraft{}, errorNoCountries
+	}
+
+	return draft, nil
+}
@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"memtravel/db"
+	"memtravel/middleware"
+)
+
+type (
+	// JournalEntry is the blueprint for a dated journal entry written during a trip
+	JournalEntry struct {
+		EntryID  int         `json:"entryid,omitempty"`
+		TripID   int         `json:"tripid,omitempty"`
+		Day      string      `json:"day"`
+		Text     string      `json:"text"`
+		Photos   []int64     `json:"photos,omitempty"`
+		Media    []TripMedia `json:"media,omitempty"`
+		Location *Location   `json:"location,omitempty"`
+	}
+
+	// Location is the blueprint for an optional place attached to a journal entry
+	Location struct {
+		Latitude  float64 `json:"lat"`
+		Longitude float64 `json:"lng"`
+		Name      string  `json:"name,omitempty"`
+	}
+)
+
+const (
+	maxJournalTextLength  = 10000
+	maxJournalPhotos      = 20
+	maxLocationNameLength = 100
+)
+
+func (handler *Handler) GetJournalHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.viewableTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	entries, deferredErr := handler.journalEntries(tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, entries)
+}
+
+func (handler *Handler) AddJournalEntryHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.ownedTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	var entry JournalEntry
+
+	deferredErr = readBody(r, &entry)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.validateJournalEntry(tripID, &entry)
+	if deferredErr != nil {
+		return
+	}
+
+	latitude, longitude, placeName := entry.Location.columns()
+
+	deferredErr = handler.database.QueryRow(
+		db.AddJournalEntry,
+		tripID,
+		entry.Day,
+		entry.Text,
+		pq.Array(entry.Photos),
+		latitude,
+		longitude,
+		placeName,
+	).Scan(&entry.EntryID)
+	if deferredErr != nil {
+		return
+	}
+
+	entries, deferredErr := handler.journalEntries(tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, entries)
+}
+
+func (handler *Handler) EditJournalEntryHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.ownedTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	entryID, deferredErr := strconv.Atoi(r.PathValue(entryParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	var entry JournalEntry
+
+	deferredErr = readBody(r, &entry)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.validateJournalEntry(tripID, &entry)
+	if deferredErr != nil {
+		return
+	}
+
+	latitude, longitude, placeName := entry.Location.columns()
+
+	deferredErr = handler.database.ExecQuery(
+		db.EditJournalEntry,
+		entry.Day,
+		entry.Text,
+		pq.Array(entry.Photos),
+		latitude,
+		longitude,
+		placeName,
+		entryID,
+		tripID,
+	)
+	if deferredErr != nil {
+		return
+	}
+
+	entries, deferredErr := handler.journalEntries(tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, entries)
+}
+
+func (handler *Handler) RemoveJournalEntryHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.ownedTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	entryID, deferredErr := strconv.Atoi(r.PathValue(entryParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.database.ExecQuery(db.RemoveJournalEntry, entryID, tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, "")
+}
+
+// journalEntries reads the journal of a trip ordered by day, with the urls of the attached photos
+func (handler *Handler) journalEntries(tripID int) ([]JournalEntry, error) {
+	photos, err := handler.tripMediaByID(tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := handler.database.Query(db.GetJournalEntries, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	entries := []JournalEntry{}
+
+	for rows.Next() {
+		var entry JournalEntry
+		var day time.Time
+		var latitude, longitude sql.NullFloat64
+		var placeName sql.NullString
+
+		err = rows.Scan(&entry.EntryID, &entry.TripID, &day, &entry.Text, pq.Array(&entry.Photos), &latitude, &longitude, &placeName)
+		if err != nil {
+			return nil, err
+		}
+
+		entry.Day = day.Format(time.DateOnly)
+
+		if latitude.Valid && longitude.Valid {
+			entry.Location = &Location{
+				Latitude:  latitude.Float64,
+				Longitude: longitude.Float64,
+				Name:      placeName.String,
+			}
+		}
+
+		for _, mediaID := range entry.Photos {
+			if photo, ok := photos[mediaID]; ok {
+				entry.Media = append(entry.Media, photo)
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// tripMediaByID reads all photos of a trip keyed by their media id
+func (handler *Handler) tripMediaByID(tripID int) (map[int64]TripMedia, error) {
+	rows, err := handler.database.Query(db.GetTripMedia, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	photos := make(map[int64]TripMedia)
+
+	for rows.Next() {
+		var photo TripMedia
+		var key, contentType string
+		var created time.Time
+
+		err = rows.Scan(&photo.MediaID, &photo.TripID, &key, &contentType, &created)
+		if err != nil {
+			return nil, err
+		}
+
+		photo.Created = created.Format(time.RFC3339)
+		photo.URL, photo.Thumbnails = mediaURLs(key)
+
+		photos[int64(photo.MediaID)] = photo
+	}
+
+	return photos, rows.Err()
+}
+
+// validateJournalEntry checks the entry request data, the day must be inside the trip dates
+// and the photos must be media already uploaded to the same trip
+func (handler *Handler) validateJournalEntry(tripID int, entry *JournalEntry) error {
+	entry.Text = strings.TrimSpace(entry.Text)
+	if entry.Text == "" || len(entry.Text) > maxJournalTextLength {
+		return errorInvalidRequestData
+	}
+
+	if len(entry.Photos) > maxJournalPhotos {
+		return errorInvalidRequestData
+	}
+
+	if entry.Location != nil {
+		entry.Location.Name = strings.TrimSpace(entry.Location.Name)
+
+		if entry.Location.Latitude < -90 || entry.Location.Latitude > 90 ||
+			entry.Location.Longitude < -180 || entry.Location.Longitude > 180 ||
+			len(entry.Location.Name) > maxLocationNameLength {
+			return errorInvalidRequestData
+		}
+	}
+
+	day, err := time.Parse(time.DateOnly, entry.Day)
+	if err != nil {
+		return err
+	}
+
+	trip, err := handler.getTrip(tripID)
+	if err != nil {
+		return err
+	}
+
+	// dates are compared as strings since both are in the time.DateOnly layout
+	entry.Day = day.Format(time.DateOnly)
+	if entry.Day < trip.StartDate || entry.Day > trip.EndDate {
+		return errorInvalidRequestData
+	}
+
+	if len(entry.Photos) > 0 {
+		var found int
+
+		err = handler.database.QueryRow(db.CountTripMedia, tripID, pq.Array(entry.Photos)).Scan(&found)
+		if err != nil {
+			return err
+		}
+
+		if found != len(entry.Photos) {
+			return errorInvalidRequestData
+		}
+	}
+
+	return nil
+}
+
+// columns returns the nullable column values used to store the location
+func (location *Location) columns() (sql.NullFloat64, sql.NullFloat64, sql.NullString) {
+	if location == nil {
+		return sql.NullFloat64{}, sql.NullFloat64{}, sql.NullString{}
+	}
+
+	return sql.NullFloat64{Float64: location.Latitude, Valid: true},
+		sql.NullFloat64{Float64: location.Longitude, Valid: true},
+		sql.NullString{String: location.Name, Valid: location.Name != ""}
+}
@@ -0,0 +1,458 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"memtravel/db"
+	"memtravel/geo"
+	"memtravel/middleware"
+)
+
+type (
+	// Leg is the blueprint for a single stop inside a trip itinerary
+	Leg struct {
+		LegID     int     `json:"legid,omitempty"`
+		TripID    int     `json:"tripid,omitempty"`
+		Position  int     `json:"position"`
+		City      string  `json:"city"`
+		Country   int     `json:"country"`
+		Arrival   string  `json:"arrival"`
+		Departure string  `json:"departure"`
+		Transport string  `json:"transport"`
+		Distance  float64 `json:"distance,omitempty"`
+		CO2       float64 `json:"co2,omitempty"`
+	}
+
+	// Itinerary is the blueprint for the ordered legs of a trip and the figures derived from them
+	Itinerary struct {
+		Legs           []Leg   `json:"legs"`
+		StartDate      string  `json:"startdate,omitempty"`
+		EndDate        string  `json:"enddate,omitempty"`
+		TotalCountries int     `json:"totalCountries"`
+		TotalCities    int     `json:"totalCities"`
+		TotalDistance  float64 `json:"totalDistance"`
+		TotalCO2       float64 `json:"totalCO2"`
+	}
+
+	// LegOrder is the blueprint for the reorder legs request
+	LegOrder struct {
+		Legs []int `json:"legs"`
+	}
+)
+
+const (
+	transportPlane = "plane"
+	transportTrain = "train"
+	transportCar   = "car"
+	transportBus   = "bus"
+	transportBoat  = "boat"
+	transportBike  = "bike"
+	transportWalk  = "walk"
+	transportOther = "other"
+)
+
+var transportModes = map[string]struct{}{
+	transportPlane: {},
+	transportTrain: {},
+	transportCar:   {},
+	transportBus:   {},
+	transportBoat:  {},
+	transportBike:  {},
+	transportWalk:  {},
+	transportOther: {},
+}
+
+func (handler *Handler) GetLegsHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.viewableTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	itinerary, deferredErr := handler.tripItinerary(tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, itinerary)
+}
+
+func (handler *Handler) AddLegHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.ownedTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	var leg Leg
+
+	deferredErr = readBody(r, &leg)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = validateLeg(&leg)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.database.ExecTransaction(
+		[]db.Transaction{
+			{
+				Query:  db.AddTripLeg,
+				Params: []any{tripID, leg.City, leg.Country, leg.Arrival, leg.Departure, leg.Transport},
+			},
+			{
+				Query:  db.SyncTripWithLegs,
+				Params: []any{tripID},
+			},
+		},
+	)
+	if deferredErr != nil {
+		return
+	}
+
+	handler.tripsChanged(userID)
+
+	itinerary, deferredErr := handler.tripItinerary(tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, itinerary)
+}
+
+func (handler *Handler) ReorderLegsHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.ownedTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	var order LegOrder
+
+	deferredErr = readBody(r, &order)
+	if deferredErr != nil {
+		return
+	}
+
+	current, deferredErr := handler.tripItinerary(tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	if len(order.Legs) != len(current.Legs) {
+		deferredErr = errorInvalidRequestData
+		return
+	}
+
+	existing := make(map[int]struct{}, len(current.Legs))
+	for _, leg := range current.Legs {
+		existing[leg.LegID] = struct{}{}
+	}
+
+	transactions := make([]db.Transaction, 0, len(order.Legs)+1)
+
+	for i, legID := range order.Legs {
+		if _, ok := existing[legID]; !ok {
+			deferredErr = fmt.Errorf("%d leg is not part of trip %d or is repeated", legID, tripID)
+			return
+		}
+
+		delete(existing, legID)
+
+		transactions = append(transactions, db.Transaction{
+			Query:  db.UpdateTripLegPosition,
+			Params: []any{i + 1, legID, tripID},
+		})
+	}
+
+	transactions = append(transactions, db.Transaction{
+		Query:  db.SyncTripWithLegs,
+		Params: []any{tripID},
+	})
+
+	deferredErr = handler.database.ExecTransaction(transactions)
+	if deferredErr != nil {
+		return
+	}
+
+	handler.tripsChanged(userID)
+
+	itinerary, deferredErr := handler.tripItinerary(tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, itinerary)
+}
+
+func (handler *Handler) RemoveLegHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.ownedTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	legID, deferredErr := strconv.Atoi(r.PathValue(legParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	current, deferredErr := handler.tripItinerary(tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	if !slices.ContainsFunc(current.Legs, func(leg Leg) bool { return leg.LegID == legID }) {
+		deferredErr = fmt.Errorf("%d leg is not part of trip %d", legID, tripID)
+		return
+	}
+
+	// the leg is removed in the same transaction so positions and trip dates never go out of sync
+	deferredErr = handler.database.ExecTransaction(
+		[]db.Transaction{
+			{
+				Query:  db.RemoveTripLeg,
+				Params: []any{legID, tripID},
+			},
+			{
+				Query:  db.RenumberTripLegs,
+				Params: []any{tripID},
+			},
+			{
+				Query:  db.SyncTripWithLegs,
+				Params: []any{tripID},
+			},
+		},
+	)
+	if deferredErr != nil {
+		return
+	}
+
+	handler.tripsChanged(userID)
+
+	itinerary, deferredErr := handler.tripItinerary(tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, itinerary)
+}
+
+// ownedTripID reads the trip id from the path and makes sure it belongs to the user
+func (handler *Handler) ownedTripID(r *http.Request, userID any) (int, error) {
+	tripID, err := strconv.Atoi(r.PathValue(pathParamID))
+	if err != nil {
+		return 0, err
+	}
+
+	owned, err := handler.tripBelongsToUser(userID, tripID)
+	if err != nil {
+		return 0, err
+	}
+
+	if !owned {
+		return 0, errorTripNotOwned
+	}
+
+	return tripID, nil
+}
+
+// tripItinerary reads the ordered legs of a trip and derives the trip dates, counters and footprint from them
+func (handler *Handler) tripItinerary(tripID int) (Itinerary, error) {
+	rows, err := handler.database.Query(db.GetTripLegs, tripID)
+	if err != nil {
+		return Itinerary{}, err
+	}
+
+	defer rows.Close()
+
+	itinerary := Itinerary{
+		Legs: []Leg{},
+	}
+
+	countries := make(map[int]struct{})
+	cities := make(map[string]struct{})
+
+	var previous *geo.Point
+	var totalDistance, totalCO2 float64
+
+	for rows.Next() {
+		var leg Leg
+		var arrival, departure time.Time
+		var latitude, longitude sql.NullFloat64
+
+		err = rows.Scan(
+			&leg.LegID,
+			&leg.TripID,
+			&leg.Position,
+			&leg.City,
+			&leg.Country,
+			&arrival,
+			&departure,
+			&leg.Transport,
+			&latitude,
+			&longitude,
+		)
+		if err != nil {
+			return Itinerary{}, err
+		}
+
+		current := stopPoint(latitude, longitude)
+
+		distance, co2 := handler.legFootprint(previous, current, leg.Transport)
+		leg.Distance = roundFootprint(distance)
+		leg.CO2 = roundFootprint(co2)
+		totalDistance += distance
+		totalCO2 += co2
+		previous = current
+
+		leg.Arrival = arrival.Format(time.DateOnly)
+		leg.Departure = departure.Format(time.DateOnly)
+
+		if itinerary.StartDate == "" || leg.Arrival < itinerary.StartDate {
+			itinerary.StartDate = leg.Arrival
+		}
+
+		if leg.Departure > itinerary.EndDate {
+			itinerary.EndDate = leg.Departure
+		}
+
+		countries[leg.Country] = struct{}{}
+		if leg.City != "" {
+			cities[strings.ToLower(leg.City)] = struct{}{}
+		}
+
+		itinerary.Legs = append(itinerary.Legs, leg)
+	}
+
+	itinerary.TotalCountries = len(countries)
+	itinerary.TotalCities = len(cities)
+	itinerary.TotalDistance = roundFootprint(totalDistance)
+	itinerary.TotalCO2 = roundFootprint(totalCO2)
+
+	return itinerary, rows.Err()
+}
+
+// validateLeg checks the leg request data and normalises it before it is stored
+func validateLeg(leg *Leg) error {
+	leg.City = strings.TrimSpace(leg.City)
+	if leg.City == "" || len(leg.City) > maxCityLength {
+		return errorInvalidRequestData
+	}
+
+	if leg.Country <= 0 {
+		return errorInvalidRequestData
+	}
+
+	if _, ok := transportModes[leg.Transport]; !ok {
+		return errorInvalidRequestData
+	}
+
+	arrival, err := time.Parse(time.DateOnly, leg.Arrival)
+	if err != nil {
+		return err
+	}
+
+	departure, err := time.Parse(time.DateOnly, leg.Departure)
+	if err != nil {
+		return err
+	}
+
+	if departure.Before(arrival) {
+		return errorInvalidRequestData
+	}
+
+	return nil
+}
+
+// legFootprint returns the great-circle distance in kilometres from the previous stop to the leg and the kilograms
+// of CO2 emitted covering it with the transport of the leg, the first leg or a stop without coordinates count as zero
+func (handler *Handler) legFootprint(previous *geo.Point, current *geo.Point, transport string) (float64, float64) {
+	if previous == nil || current == nil {
+		return 0, 0
+	}
+
+	distance := geo.Distance(*previous, *current)
+
+	return distance, handler.emissions.Estimate(transport, distance)
+}
+
+// stopPoint builds the point of a stop out of nullable coordinates
+func stopPoint(latitude sql.NullFloat64, longitude sql.NullFloat64) *geo.Point {
+	if !latitude.Valid || !longitude.Valid {
+		return nil
+	}
+
+	return &geo.Point{Latitude: latitude.Float64, Longitude: longitude.Float64}
+}
+
+// roundFootprint rounds distances and emissions to one decimal place
+func roundFootprint(value float64) float64 {
+	return math.Round(value*10) / 10
+}
@@ -0,0 +1,461 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"memtravel/configs"
+	"memtravel/db"
+	"memtravel/media"
+	"memtravel/middleware"
+)
+
+type (
+	// TripMedia is the blueprint for a photo attached to a trip
+	TripMedia struct {
+		MediaID    int               `json:"mediaid"`
+		TripID     int               `json:"tripid"`
+		URL        string            `json:"url"`
+		Thumbnails map[string]string `json:"thumbnails"`
+		Created    string            `json:"created"`
+	}
+
+	// UploadedImage is the blueprint for the response of the avatar and cover uploads
+	UploadedImage struct {
+		URL        string            `json:"url"`
+		Thumbnails map[string]string `json:"thumbnails"`
+	}
+)
+
+const (
+	uploadFormField = "file"
+
+	// avatars are shown next to usernames everywhere so they do not need a signature to be served
+	publicMediaPrefix = "avatars/"
+
+	signedMediaDuration = time.Hour
+)
+
+var errorInvalidMediaSignature = errors.New("invalid or expired media signature")
+
+func (handler *Handler) UploadAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	data, deferredErr := readUpload(w, r)
+	if deferredErr != nil {
+		return
+	}
+
+	var previousPicture string
+
+	deferredErr = handler.database.QueryRow(db.GetUserProfilePicture, userID).Scan(&previousPicture)
+	if deferredErr != nil {
+		return
+	}
+
+	key, _, deferredErr := media.SaveImage(handler.storage, publicMediaPrefix+fmt.Sprint(userID), data)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.database.ExecQuery(db.UpdateUserProfilePicture, media.URLPrefix+key, userID)
+	if deferredErr != nil {
+		deferredErr = errors.Join(deferredErr, media.DeleteImage(handler.storage, key))
+		return
+	}
+
+	previousKey := strings.TrimPrefix(previousPicture, media.URLPrefix)
+	if strings.HasPrefix(previousKey, publicMediaPrefix) {
+		deferredErr = media.DeleteImage(handler.storage, previousKey)
+		if deferredErr != nil {
+			return
+		}
+	}
+
+	deferredErr = writeServerResponse(w, true, uploadedImage(key))
+}
+
+func (handler *Handler) UploadTripCoverHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.ownedTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	data, deferredErr := readUpload(w, r)
+	if deferredErr != nil {
+		return
+	}
+
+	var previousCover string
+
+	deferredErr = handler.database.QueryRow(db.GetTripCover, tripID).Scan(&previousCover)
+	if deferredErr != nil {
+		return
+	}
+
+	key, _, deferredErr := media.SaveImage(handler.storage, tripMediaPrefix(tripID), data)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.database.ExecQuery(db.UpdateTripCover, key, tripID, userID)
+	if deferredErr != nil {
+		deferredErr = errors.Join(deferredErr, media.DeleteImage(handler.storage, key))
+		return
+	}
+
+	if previousCover != "" {
+		deferredErr = media.DeleteImage(handler.storage, previousCover)
+		if deferredErr != nil {
+			return
+		}
+	}
+
+	invalidateTripCaches(userID)
+
+	deferredErr = writeServerResponse(w, true, uploadedImage(key))
+}
+
+func (handler *Handler) AddTripMediaHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.ownedTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	data, deferredErr := readUpload(w, r)
+	if deferredErr != nil {
+		return
+	}
+
+	key, contentType, deferredErr := media.SaveImage(handler.storage, tripMediaPrefix(tripID), data)
+	if deferredErr != nil {
+		return
+	}
+
+	tripMedia := TripMedia{
+		TripID: tripID,
+	}
+
+	var created time.Time
+
+	// the stored files are removed again if the photo could not be added, nothing would point to them
+	deferredErr = handler.database.QueryRow(db.AddTripMedia, tripID, key, contentType).Scan(&tripMedia.MediaID, &created)
+	if deferredErr != nil {
+		deferredErr = errors.Join(deferredErr, media.DeleteImage(handler.storage, key))
+		return
+	}
+
+	tripMedia.Created = created.Format(time.RFC3339)
+	tripMedia.URL, tripMedia.Thumbnails = mediaURLs(key)
+
+	deferredErr = writeServerResponse(w, true, tripMedia)
+}
+
+func (handler *Handler) GetTripMediaHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.viewableTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	rows, deferredErr := handler.database.Query(db.GetTripMedia, tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	tripMedia := []TripMedia{}
+
+	for rows.Next() {
+		var photo TripMedia
+		var key, contentType string
+		var created time.Time
+
+		deferredErr = rows.Scan(&photo.MediaID, &photo.TripID, &key, &contentType, &created)
+		if deferredErr != nil {
+			return
+		}
+
+		photo.Created = created.Format(time.RFC3339)
+		photo.URL, photo.Thumbnails = mediaURLs(key)
+
+		tripMedia = append(tripMedia, photo)
+	}
+
+	deferredErr = rows.Err()
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, tripMedia)
+}
+
+func (handler *Handler) RemoveTripMediaHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.ownedTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	mediaID, deferredErr := strconv.Atoi(r.PathValue(mediaParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	var key string
+
+	deferredErr = handler.database.QueryRow(db.GetTripMediaKey, mediaID, tripID).Scan(&key)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.database.ExecTransaction(
+		[]db.Transaction{
+			{
+				Query:  db.RemoveTripMedia,
+				Params: []any{mediaID, tripID},
+			},
+			{
+				Query:  db.RemoveJournalEntryPhotos,
+				Params: []any{mediaID, tripID},
+			},
+		},
+	)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = media.DeleteImage(handler.storage, key)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, "")
+}
+
+func (handler *Handler) ServeMediaHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+			)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+	}()
+
+	key := r.PathValue(mediaKeyParamID)
+
+	if !strings.HasPrefix(key, publicMediaPrefix) {
+		query := r.URL.Query()
+		if !media.VerifySignature(key, query.Get("exp"), query.Get("sig"), configs.Envs.MediaSecret) {
+			deferredErr = errorInvalidMediaSignature
+			return
+		}
+	}
+
+	cacheControl := "private, max-age=3600"
+	if strings.HasPrefix(key, publicMediaPrefix) {
+		cacheControl = "public, max-age=86400"
+	}
+
+	deferredErr = handler.serveMedia(w, r, key, cacheControl)
+}
+
+// serveMedia writes the stored file of the key, the caller must have checked the request is allowed to read it
+func (handler *Handler) serveMedia(w http.ResponseWriter, r *http.Request, key string, cacheControl string) error {
+	file, err := handler.storage.Open(key)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	w.Header().Set("Content-Type", media.ContentType(key))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", cacheControl)
+
+	_, err = io.Copy(w, file)
+	if err != nil {
+		log.Printf("Error: [%s], context_id: [%s]", err.Error(), r.Context().Value(middleware.RequestContextID))
+	}
+
+	return nil
+}
+
+// readUpload reads the uploaded file of a multipart request making sure it is not bigger than media.MaxUploadSize
+func readUpload(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, media.MaxUploadSize+1<<20)
+
+	err := r.ParseMultipartForm(media.MaxUploadSize)
+	if err != nil {
+		return nil, err
+	}
+
+	file, header, err := r.FormFile(uploadFormField)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	if header.Size > media.MaxUploadSize {
+		return nil, errorInvalidRequestData
+	}
+
+	return io.ReadAll(io.LimitReader(file, media.MaxUploadSize))
+}
+
+// tripMediaKeys reads the keys of the cover and of every photo of the trip
+func (handler *Handler) tripMediaKeys(tripID int) ([]string, error) {
+	rows, err := handler.database.Query(db.GetTripMediaKeys, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	keys := []string{}
+
+	for rows.Next() {
+		var key string
+
+		err = rows.Scan(&key)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// removeMediaFiles deletes the stored images and their thumbnails, a failure is only logged since the rows
+// pointing to them were already removed
+func (handler *Handler) removeMediaFiles(keys []string) {
+	for _, key := range keys {
+		err := media.DeleteImage(handler.storage, key)
+		if err != nil {
+			log.Printf("Error: [%s], media_key: [%s]", err.Error(), key)
+		}
+	}
+}
+
+// tripMediaPrefix is the storage prefix of every file uploaded for a trip
+func tripMediaPrefix(tripID int) string {
+	return "trips/" + strconv.Itoa(tripID)
+}
+
+// mediaURL converts a stored media key into a url that can be used by the app,
+// private keys get a signed url that expires after signedMediaDuration
+func mediaURL(key string) string {
+	if key == "" || strings.HasPrefix(key, media.URLPrefix) {
+		return key
+	}
+
+	if strings.HasPrefix(key, publicMediaPrefix) {
+		return media.URLPrefix + key
+	}
+
+	return media.SignURL(key, time.Now().Add(signedMediaDuration), configs.Envs.MediaSecret)
+}
+
+// mediaURLs returns the url of an image and of all its thumbnails
+func mediaURLs(key string) (string, map[string]string) {
+	thumbnails := make(map[string]string, len(media.ThumbnailSizes))
+	for _, size := range media.ThumbnailSizes {
+		thumbnails[size.Name] = mediaURL(media.ThumbnailKey(key, size.Name))
+	}
+
+	return mediaURL(key), thumbnails
+}
+
+func uploadedImage(key string) UploadedImage {
+	url, thumbnails := mediaURLs(key)
+
+	return UploadedImage{
+		URL:        url,
+		Thumbnails: thumbnails,
+	}
+}
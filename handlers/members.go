@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"memtravel/db"
+	"memtravel/middleware"
+)
+
+var (
+	errorNotTripParticipant = errors.New("user is not part of the trip")
+)
+
+func (handler *Handler) GetTripMembersHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.participantTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	members, deferredErr := handler.tripMembers(tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, members)
+}
+
+func (handler *Handler) AddTripMemberHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.ownedTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	friendParam := r.URL.Query().Get(friendParamID)
+	friendID, deferredErr := strconv.Atoi(friendParam)
+	if deferredErr != nil {
+		return
+	}
+
+	rows, deferredErr := handler.database.Query(db.CheckIfUserHasFriend, userID, friendID)
+	if deferredErr != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		deferredErr = fmt.Errorf("%s is not a friend of the user", friendParam)
+		return
+	}
+
+	deferredErr = handler.database.ExecQuery(db.AddTripMember, tripID, friendID)
+	if deferredErr != nil {
+		return
+	}
+
+	members, deferredErr := handler.tripMembers(tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, members)
+}
+
+func (handler *Handler) RemoveTripMemberHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.ownedTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	friendID, deferredErr := strconv.Atoi(r.URL.Query().Get(friendParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.database.ExecQuery(db.RemoveTripMember, tripID, friendID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, "")
+}
+
+// participantTripID reads the trip id from the path and makes sure the user owns the trip or is one of its members
+func (handler *Handler) participantTripID(r *http.Request, userID any) (int, error) {
+	tripID, err := strconv.Atoi(r.PathValue(pathParamID))
+	if err != nil {
+		return 0, err
+	}
+
+	var participant bool
+
+	err = handler.database.QueryRow(db.IsTripParticipant, tripID, userID).Scan(&participant)
+	if err != nil {
+		return 0, err
+	}
+
+	if !participant {
+		return 0, errorNotTripParticipant
+	}
+
+	return tripID, nil
+}
+
+// tripMembers reads the friends that were added to a trip, the owner is not part of the list
+func (handler *Handler) tripMembers(tripID int) ([]User, error) {
+	rows, err := handler.database.Query(db.GetTripMembers, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	members := []User{}
+
+	for rows.Next() {
+		var member User
+
+		err = rows.Scan(&member.UserID, &member.FullName, &member.ProfilePicture)
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
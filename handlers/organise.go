@@ -0,0 +1,744 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"memtravel/db"
+	"memtravel/middleware"
+)
+
+type (
+	// Plan is the blueprint for a group trip being organised between friends
+	Plan struct {
+		PlanID       int               `json:"planid,omitempty"`
+		OwnerID      int               `json:"ownerid,omitempty"`
+		Title        string            `json:"title"`
+		Status       string            `json:"status,omitempty"`
+		MemberStatus string            `json:"memberStatus,omitempty"`
+		Destination  *PlanDestination  `json:"destination,omitempty"`
+		Dates        *PlanDate         `json:"dates,omitempty"`
+		Members      []PlanMember      `json:"members,omitempty"`
+		Destinations []PlanDestination `json:"destinations,omitempty"`
+		DateOptions  []PlanDate        `json:"dateOptions,omitempty"`
+		Votes        map[string]int    `json:"votes,omitempty"`
+	}
+
+	// PlanMember is the blueprint for a user invited to a plan
+	PlanMember struct {
+		UserID         int    `json:"userid"`
+		FullName       string `json:"fullname"`
+		ProfilePicture string `json:"profilepic,omitempty"`
+		Status         string `json:"status"`
+	}
+
+	// PlanDestination is the blueprint for a destination proposed in a plan
+	PlanDestination struct {
+		DestinationID int    `json:"destinationid,omitempty"`
+		Country       int    `json:"country"`
+		City          string `json:"city,omitempty"`
+		ProposedBy    int    `json:"proposedby,omitempty"`
+		Votes         int    `json:"votes"`
+	}
+
+	// PlanDate is the blueprint for dates proposed in a plan
+	PlanDate struct {
+		DateID     int    `json:"dateid,omitempty"`
+		StartDate  string `json:"startdate"`
+		EndDate    string `json:"enddate"`
+		ProposedBy int    `json:"proposedby,omitempty"`
+		Votes      int    `json:"votes"`
+	}
+
+	// PlanInvite is the blueprint for the invite friends to a plan request
+	PlanInvite struct {
+		PlanID  int   `json:"planid"`
+		Friends []int `json:"friends"`
+	}
+
+	// PlanVote is the blueprint for the vote request
+	PlanVote struct {
+		Option int `json:"option"`
+	}
+
+	// planMembership holds the status of a user inside a plan
+	planMembership struct {
+		status     string
+		ownerID    int
+		planStatus string
+	}
+)
+
+const (
+	planStatusOpen = "open"
+
+	memberStatusInvited  = "invited"
+	memberStatusAccepted = "accepted"
+	memberStatusDeclined = "declined"
+
+	voteKindDestination = "destination"
+	voteKindDate        = "date"
+
+	maxPlanTitleLength = 60
+	maxPlanInvites     = 30
+)
+
+var planResponses = map[string]string{
+	acceptFriendRequest:  memberStatusAccepted,
+	declineFriendRequest: memberStatusDeclined,
+}
+
+var voteKinds = map[string]string{
+	voteKindDestination: db.PlanDestinationExists,
+	voteKindDate:        db.PlanDateExists,
+}
+
+var (
+	errorNotPlanMember  = errors.New("user is not an accepted member of the plan")
+	errorNotPlanOwner   = errors.New("user is not the owner of the plan")
+	errorPlanNotOpen    = errors.New("plan is already settled")
+	errorPlanUndecided  = errors.New("plan needs at least one destination and one date to be settled")
+	errorNotPlanInvitee = errors.New("user was not invited to the plan")
+)
+
+func (handler *Handler) CreatePlanHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	var plan Plan
+
+	deferredErr = readBody(r, &plan)
+	if deferredErr != nil {
+		return
+	}
+
+	plan.Title = strings.TrimSpace(plan.Title)
+	if plan.Title == "" || len(plan.Title) > maxPlanTitleLength {
+		deferredErr = errorInvalidRequestData
+		return
+	}
+
+	deferredErr = handler.database.QueryRow(db.AddPlan, userID, plan.Title).Scan(&plan.PlanID)
+	if deferredErr != nil {
+		return
+	}
+
+	plan, deferredErr = handler.planDetails(plan.PlanID, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, plan)
+}
+
+func (handler *Handler) GetPlansHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	rows, deferredErr := handler.database.Query(db.GetUserPlans, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	plans := []Plan{}
+
+	for rows.Next() {
+		var plan Plan
+
+		deferredErr = rows.Scan(&plan.PlanID, &plan.OwnerID, &plan.Title, &plan.Status, &plan.MemberStatus)
+		if deferredErr != nil {
+			return
+		}
+
+		plans = append(plans, plan)
+	}
+
+	deferredErr = rows.Err()
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, plans)
+}
+
+func (handler *Handler) GetPlanHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	planID, deferredErr := strconv.Atoi(r.PathValue(pathParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	// invited users can see the plan before deciding to accept it
+	membership, deferredErr := handler.planMembership(planID, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	if membership.status == memberStatusDeclined {
+		deferredErr = errorNotPlanMember
+		return
+	}
+
+	plan, deferredErr := handler.planDetails(planID, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, plan)
+}
+
+func (handler *Handler) OrganiseRequestHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	var invite PlanInvite
+
+	deferredErr = readBody(r, &invite)
+	if deferredErr != nil {
+		return
+	}
+
+	if len(invite.Friends) == 0 || len(invite.Friends) > maxPlanInvites {
+		deferredErr = errorInvalidRequestData
+		return
+	}
+
+	membership, deferredErr := handler.planMembership(invite.PlanID, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	if strconv.Itoa(membership.ownerID) != fmt.Sprint(userID) {
+		deferredErr = errorNotPlanOwner
+		return
+	}
+
+	if membership.planStatus != planStatusOpen {
+		deferredErr = errorPlanNotOpen
+		return
+	}
+
+	transactions := make([]db.Transaction, 0, len(invite.Friends))
+
+	for _, friendID := range invite.Friends {
+		var rows *sql.Rows
+		rows, deferredErr = handler.database.Query(db.CheckIfUserHasFriend, userID, friendID)
+		if deferredErr != nil {
+			return
+		}
+
+		isFriend := rows.Next()
+		rows.Close()
+
+		if !isFriend {
+			deferredErr = fmt.Errorf("%d is not a friend of the user", friendID)
+			return
+		}
+
+		transactions = append(transactions, db.Transaction{
+			Query:  db.InvitePlanMember,
+			Params: []any{invite.PlanID, friendID},
+		})
+	}
+
+	deferredErr = handler.database.ExecTransaction(transactions)
+	if deferredErr != nil {
+		return
+	}
+
+	plan, deferredErr := handler.planDetails(invite.PlanID, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, plan)
+}
+
+func (handler *Handler) RespondPlanHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	planID, deferredErr := strconv.Atoi(r.PathValue(pathParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	response := r.PathValue(responseParamID)
+	status, validResponse := planResponses[response]
+	if !validResponse {
+		deferredErr = fmt.Errorf("%s is not a valid response", response)
+		return
+	}
+
+	membership, deferredErr := handler.planMembership(planID, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	if membership.status != memberStatusInvited {
+		deferredErr = errorNotPlanInvitee
+		return
+	}
+
+	deferredErr = handler.database.ExecQuery(db.UpdatePlanMemberStatus, status, planID, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, "")
+}
+
+func (handler *Handler) AddPlanDestinationHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	planID, deferredErr := handler.openPlanID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	var destination PlanDestination
+
+	deferredErr = readBody(r, &destination)
+	if deferredErr != nil {
+		return
+	}
+
+	destination.City = strings.TrimSpace(destination.City)
+	if destination.Country <= 0 || len(destination.City) > maxCityLength {
+		deferredErr = errorInvalidRequestData
+		return
+	}
+
+	deferredErr = handler.database.ExecQuery(db.AddPlanDestination, planID, destination.Country, destination.City, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	plan, deferredErr := handler.planDetails(planID, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, plan)
+}
+
+func (handler *Handler) AddPlanDateHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	planID, deferredErr := handler.openPlanID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	var dates PlanDate
+
+	deferredErr = readBody(r, &dates)
+	if deferredErr != nil {
+		return
+	}
+
+	startDate, deferredErr := time.Parse(time.DateOnly, dates.StartDate)
+	if deferredErr != nil {
+		return
+	}
+
+	endDate, deferredErr := time.Parse(time.DateOnly, dates.EndDate)
+	if deferredErr != nil {
+		return
+	}
+
+	if endDate.Before(startDate) {
+		deferredErr = errorInvalidRequestData
+		return
+	}
+
+	deferredErr = handler.database.ExecQuery(db.AddPlanDate, planID, dates.StartDate, dates.EndDate, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	plan, deferredErr := handler.planDetails(planID, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, plan)
+}
+
+func (handler *Handler) VotePlanHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	planID, deferredErr := handler.openPlanID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	kind := r.PathValue(voteKindParamID)
+	optionExistsQuery, validKind := voteKinds[kind]
+	if !validKind {
+		deferredErr = fmt.Errorf("%s is not a valid vote kind", kind)
+		return
+	}
+
+	var vote PlanVote
+
+	deferredErr = readBody(r, &vote)
+	if deferredErr != nil {
+		return
+	}
+
+	var optionExists bool
+
+	deferredErr = handler.database.QueryRow(optionExistsQuery, vote.Option, planID).Scan(&optionExists)
+	if deferredErr != nil {
+		return
+	}
+
+	if !optionExists {
+		deferredErr = fmt.Errorf("%d is not a %s option of plan %d", vote.Option, kind, planID)
+		return
+	}
+
+	deferredErr = handler.database.ExecQuery(db.UpsertPlanVote, planID, userID, kind, vote.Option)
+	if deferredErr != nil {
+		return
+	}
+
+	plan, deferredErr := handler.planDetails(planID, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, plan)
+}
+
+func (handler *Handler) SettlePlanHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	planID, deferredErr := handler.openPlanID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	plan, deferredErr := handler.planDetails(planID, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	if strconv.Itoa(plan.OwnerID) != fmt.Sprint(userID) {
+		deferredErr = errorNotPlanOwner
+		return
+	}
+
+	// options are sorted by votes, ties are won by the option proposed first
+	if len(plan.Destinations) == 0 || len(plan.DateOptions) == 0 {
+		deferredErr = errorPlanUndecided
+		return
+	}
+
+	deferredErr = handler.database.ExecQuery(db.SettlePlan, plan.Destinations[0].DestinationID, plan.DateOptions[0].DateID, planID, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	plan, deferredErr = handler.planDetails(planID, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, plan)
+}
+
+// planMembership reads the status of the user inside a plan, users that were never invited get sql.ErrNoRows
+func (handler *Handler) planMembership(planID int, userID any) (planMembership, error) {
+	var membership planMembership
+
+	err := handler.database.QueryRow(db.GetPlanMembership, planID, userID).Scan(&membership.status, &membership.ownerID, &membership.planStatus)
+	if err != nil {
+		return planMembership{}, err
+	}
+
+	return membership, nil
+}
+
+// openPlanID reads the plan id from the path and makes sure the plan is still open and the user accepted to be part of it
+func (handler *Handler) openPlanID(r *http.Request, userID any) (int, error) {
+	planID, err := strconv.Atoi(r.PathValue(pathParamID))
+	if err != nil {
+		return 0, err
+	}
+
+	membership, err := handler.planMembership(planID, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if membership.status != memberStatusAccepted {
+		return 0, errorNotPlanMember
+	}
+
+	if membership.planStatus != planStatusOpen {
+		return 0, errorPlanNotOpen
+	}
+
+	return planID, nil
+}
+
+// planDetails reads a plan with its members, the proposed options sorted by votes and the votes of the user
+func (handler *Handler) planDetails(planID int, userID any) (Plan, error) {
+	var plan Plan
+	var destinationID, dateID sql.NullInt64
+
+	err := handler.database.QueryRow(db.GetPlan, planID).Scan(&plan.PlanID, &plan.OwnerID, &plan.Title, &plan.Status, &destinationID, &dateID)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	plan.Members, err = handler.planMembers(planID)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	plan.Destinations, err = handler.planDestinations(planID)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	plan.DateOptions, err = handler.planDates(planID)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	for i := range plan.Destinations {
+		if destinationID.Valid && int64(plan.Destinations[i].DestinationID) == destinationID.Int64 {
+			plan.Destination = &plan.Destinations[i]
+		}
+	}
+
+	for i := range plan.DateOptions {
+		if dateID.Valid && int64(plan.DateOptions[i].DateID) == dateID.Int64 {
+			plan.Dates = &plan.DateOptions[i]
+		}
+	}
+
+	rows, err := handler.database.Query(db.GetUserPlanVotes, planID, userID)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	defer rows.Close()
+
+	plan.Votes = make(map[string]int)
+
+	for rows.Next() {
+		var kind string
+		var optionID int
+
+		err = rows.Scan(&kind, &optionID)
+		if err != nil {
+			return Plan{}, err
+		}
+
+		plan.Votes[kind] = optionID
+	}
+
+	return plan, rows.Err()
+}
+
+func (handler *Handler) planMembers(planID int) ([]PlanMember, error) {
+	rows, err := handler.database.Query(db.GetPlanMembers, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var members []PlanMember
+
+	for rows.Next() {
+		var member PlanMember
+
+		err = rows.Scan(&member.UserID, &member.FullName, &member.ProfilePicture, &member.Status)
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+func (handler *Handler) planDestinations(planID int) ([]PlanDestination, error) {
+	rows, err := handler.database.Query(db.GetPlanDestinations, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var destinations []PlanDestination
+
+	for rows.Next() {
+		var destination PlanDestination
+
+		err = rows.Scan(&destination.DestinationID, &destination.Country, &destination.City, &destination.ProposedBy, &destination.Votes)
+		if err != nil {
+			return nil, err
+		}
+
+		destinations = append(destinations, destination)
+	}
+
+	return destinations, rows.Err()
+}
+
+func (handler *Handler) planDates(planID int) ([]PlanDate, error) {
+	rows, err := handler.database.Query(db.GetPlanDates, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var dates []PlanDate
+
+	for rows.Next() {
+		var date PlanDate
+		var startDate, endDate time.Time
+
+		err = rows.Scan(&date.DateID, &startDate, &endDate, &date.ProposedBy, &date.Votes)
+		if err != nil {
+			return nil, err
+		}
+
+		date.StartDate = startDate.Format(time.DateOnly)
+		date.EndDate = endDate.Format(time.DateOnly)
+
+		dates = append(dates, date)
+	}
+
+	return dates, rows.Err()
+}
@@ -7,6 +7,9 @@ import (
 	"memtravel/middleware"
 	"net/http"
 	"strconv"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 func (handler *Handler) AddPinnedHandler(w http.ResponseWriter, r *http.Request) {
@@ -30,14 +33,12 @@ func (handler *Handler) AddPinnedHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	rows, deferredErr := handler.database.Query(db.TripBelongsToUser, userID, tripID)
+	owned, deferredErr := handler.tripBelongsToUser(userID, tripID)
 	if deferredErr != nil {
 		return
 	}
 
-	defer rows.Close()
-
-	if !rows.Next() {
+	if !owned {
 		deferredErr = fmt.Errorf("%d trip does not belong to user", tripID)
 		return
 	}
@@ -79,3 +80,66 @@ func (handler *Handler) RemovePinnedHandler(w http.ResponseWriter, r *http.Reque
 
 	deferredErr = writeServerResponse(w, true, "")
 }
+
+func (handler *Handler) GetPinnedHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	ownerID, deferredErr := strconv.Atoi(r.PathValue(pathParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	_, visibilities, deferredErr := handler.tripLevels(userID, ownerID)
+	if deferredErr != nil {
+		return
+	}
+
+	pinned, deferredErr := handler.pinnedTrips(ownerID, visibilities)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, pinned)
+}
+
+// pinnedTrips reads the trips pinned by the owner, only counting the visibility levels given
+func (handler *Handler) pinnedTrips(ownerID int, visibilities []int64) ([]PinnedTrip, error) {
+	rows, err := handler.database.Query(db.GetPinnedTrips, ownerID, pq.Array(visibilities))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	pinned := []PinnedTrip{}
+
+	for rows.Next() {
+		var trip PinnedTrip
+		var startDate time.Time
+
+		err = rows.Scan(&trip.TripID, &trip.Cover, &trip.Country, &startDate)
+		if err != nil {
+			return nil, err
+		}
+
+		trip.Cover = mediaURL(trip.Cover)
+		trip.StartDate = startDate.Format(time.DateOnly)
+
+		pinned = append(pinned, trip)
+	}
+
+	return pinned, rows.Err()
+}
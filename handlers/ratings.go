@@ -1,18 +1,315 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"memtravel/cache"
+	"memtravel/db"
+	"memtravel/middleware"
+)
+
+type (
+	// Rating is the blueprint for the rating a user gives to a country, city or trip
+	Rating struct {
+		RatingID int    `json:"ratingid,omitempty"`
+		Target   string `json:"target"`
+		Country  int    `json:"country,omitempty"`
+		City     string `json:"city,omitempty"`
+		TripID   int    `json:"tripid,omitempty"`
+		Stars    int    `json:"stars"`
+		Review   string `json:"review,omitempty"`
+	}
+
+	// RatingSummary is the blueprint for the aggregated ratings of a country or city,
+	// Distribution holds the amount of ratings given for each star from 1 to 5
+	RatingSummary struct {
+		Country      int     `json:"country,omitempty"`
+		City         string  `json:"city,omitempty"`
+		Average      float64 `json:"average"`
+		Total        int     `json:"total"`
+		Distribution [5]int  `json:"distribution"`
+	}
+
+	// CountryRatings is the blueprint for the ratings of a country and of its cities
+	CountryRatings struct {
+		RatingSummary
+		Cities []RatingSummary `json:"cities"`
+	}
+)
+
+const (
+	ratingTargetCountry = "country"
+	ratingTargetCity    = "city"
+	ratingTargetTrip    = "trip"
+
+	maxReviewLength = 2000
+
+	allCountryRatingsKey = "all"
+)
+
+var ratingTargets = map[string]struct{}{
+	ratingTargetCountry: {},
+	ratingTargetCity:    {},
+	ratingTargetTrip:    {},
+}
+
+var (
+	errorNotVisited = errors.New("rating target was not visited by the user")
 )
 
+var ratingsCache = cache.NewCache()
+
 func (handler *Handler) AddRatingHandler(w http.ResponseWriter, r *http.Request) {
 	var deferredErr error
 	defer func() {
 		if deferredErr != nil {
-			log.Printf("Error: %s", deferredErr.Error())
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 	}()
 
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	var rating Rating
+
+	deferredErr = readBody(r, &rating)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = validateRating(&rating)
+	if deferredErr != nil {
+		return
+	}
+
+	var visited bool
+
+	switch rating.Target {
+	case ratingTargetCountry:
+		deferredErr = handler.database.QueryRow(db.HasVisitedCountry, userID, rating.Country).Scan(&visited)
+	case ratingTargetCity:
+		deferredErr = handler.database.QueryRow(db.HasVisitedCity, userID, rating.Country, rating.City).Scan(&visited)
+	case ratingTargetTrip:
+		deferredErr = handler.database.QueryRow(db.HasTakenTrip, userID, rating.TripID).Scan(&visited)
+	}
+
+	if deferredErr != nil {
+		return
+	}
+
+	if !visited {
+		deferredErr = errorNotVisited
+		return
+	}
+
+	deferredErr = handler.database.QueryRow(
+		db.UpsertRating,
+		userID,
+		rating.Target,
+		rating.Country,
+		rating.City,
+		rating.TripID,
+		rating.Stars,
+		rating.Review,
+	).Scan(&rating.RatingID)
+	if deferredErr != nil {
+		return
+	}
+
+	if rating.Target != ratingTargetTrip {
+		ratingsCache.Delete(allCountryRatingsKey)
+		ratingsCache.Delete(strconv.Itoa(rating.Country))
+	}
+
+	deferredErr = writeServerResponse(w, true, rating)
+}
+
+func (handler *Handler) GetCountriesRatingsHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	if cachedRatings, ok := ratingsCache.Get(allCountryRatingsKey); ok {
+		writeServerResponse(w, true, cachedRatings)
+		return
+	}
+
+	rows, deferredErr := handler.database.Query(db.GetCountryRatings)
+	if deferredErr != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	summaries := []RatingSummary{}
+
+	for rows.Next() {
+		var summary RatingSummary
+
+		deferredErr = scanRatingSummary(rows, &summary, &summary.Country)
+		if deferredErr != nil {
+			return
+		}
+
+		summaries = append(summaries, summary)
+	}
+
+	deferredErr = rows.Err()
+	if deferredErr != nil {
+		return
+	}
+
+	ratingsCache.Set(allCountryRatingsKey, summaries, 10*time.Minute)
+
+	deferredErr = writeServerResponse(w, true, summaries)
+}
+
+func (handler *Handler) GetCountryRatingsHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	countryID, deferredErr := strconv.Atoi(r.PathValue(countryParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	if cachedRatings, ok := ratingsCache.Get(strconv.Itoa(countryID)); ok {
+		writeServerResponse(w, true, cachedRatings)
+		return
+	}
+
+	ratings := CountryRatings{
+		RatingSummary: RatingSummary{
+			Country: countryID,
+		},
+		Cities: []RatingSummary{},
+	}
+
+	deferredErr = scanRatingSummary(handler.database.QueryRow(db.GetCountryRating, countryID), &ratings.RatingSummary, &ratings.Country)
+	if deferredErr != nil && deferredErr != sql.ErrNoRows {
+		return
+	}
+
+	rows, deferredErr := handler.database.Query(db.GetCountryCityRatings, countryID)
+	if deferredErr != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		summary := RatingSummary{
+			Country: countryID,
+		}
+
+		deferredErr = scanRatingSummary(rows, &summary, &summary.City)
+		if deferredErr != nil {
+			return
+		}
+
+		ratings.Cities = append(ratings.Cities, summary)
+	}
+
+	deferredErr = rows.Err()
+	if deferredErr != nil {
+		return
+	}
+
+	ratingsCache.Set(strconv.Itoa(countryID), ratings, 10*time.Minute)
+
+	deferredErr = writeServerResponse(w, true, ratings)
+}
+
+// scanRatingSummary reads an aggregated ratings row, the first column is scanned into groupedBy
+func scanRatingSummary(row interface{ Scan(...any) error }, summary *RatingSummary, groupedBy any) error {
+	err := row.Scan(
+		groupedBy,
+		&summary.Total,
+		&summary.Average,
+		&summary.Distribution[0],
+		&summary.Distribution[1],
+		&summary.Distribution[2],
+		&summary.Distribution[3],
+		&summary.Distribution[4],
+	)
+	if err != nil {
+		return err
+	}
+
+	summary.Average = math.Round(summary.Average*100) / 100
+
+	return nil
+}
+
+// validateRating checks the rating request data and clears the fields that are not part of its target
+func validateRating(rating *Rating) error {
+	if _, ok := ratingTargets[rating.Target]; !ok {
+		return fmt.Errorf("%s is not a valid rating target", rating.Target)
+	}
+
+	if rating.Stars < 1 || rating.Stars > 5 {
+		return errorInvalidRequestData
+	}
+
+	rating.Review = strings.TrimSpace(rating.Review)
+	if len(rating.Review) > maxReviewLength {
+		return errorInvalidRequestData
+	}
+
+	// cities are stored in lower case so the same city is always a single target
+	rating.City = strings.ToLower(strings.TrimSpace(rating.City))
+
+	switch rating.Target {
+	case ratingTargetCountry:
+		rating.City = ""
+		rating.TripID = 0
+		if rating.Country <= 0 {
+			return errorInvalidRequestData
+		}
+	case ratingTargetCity:
+		rating.TripID = 0
+		if rating.Country <= 0 || rating.City == "" || len(rating.City) > maxCityLength {
+			return errorInvalidRequestData
+		}
+	case ratingTargetTrip:
+		rating.Country = 0
+		rating.City = ""
+		if rating.TripID <= 0 {
+			return errorInvalidRequestData
+		}
+	}
+
+	return nil
 }
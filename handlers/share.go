@@ -0,0 +1,474 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"memtravel/configs"
+	"memtravel/db"
+	"memtravel/media"
+	"memtravel/middleware"
+)
+
+type (
+	// TripShare is the blueprint for a link that shows a trip to anyone that has it, the url is only known when
+	// the link is created, ExpiresIn is the number of days the link works for and zero keeps it working until revoked
+	TripShare struct {
+		ShareID   int    `json:"shareid,omitempty"`
+		URL       string `json:"url,omitempty"`
+		ExpiresIn int    `json:"expiresin,omitempty"`
+		Expires   string `json:"expires,omitempty"`
+		Created   string `json:"created,omitempty"`
+	}
+
+	// SharedTripPage is the blueprint for the data of the shared trip html page
+	SharedTripPage struct {
+		Title       string
+		Description string
+		Dates       string
+		Cover       string
+		URL         string
+		Route       []SharedStop
+		Highlights  []SharedHighlight
+	}
+
+	// SharedStop is the blueprint for a stop of the route of a shared trip
+	SharedStop struct {
+		Place string
+		Dates string
+	}
+
+	// SharedHighlight is the blueprint for a journal entry shown on a shared trip page
+	SharedHighlight struct {
+		Day   string
+		Text  string
+		Photo string
+	}
+)
+
+const (
+	sharePath               = "/share/"
+	shareMediaPath          = "/media/"
+	sharedTripTemplate      = "sharedtrip.html"
+	maxShareDays            = 365
+	maxSharedHighlights     = 6
+	maxSharedHighlightText  = 400
+	maxSharedDescriptionLen = 200
+)
+
+func (handler *Handler) GetTripSharesHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.ownedTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	rows, deferredErr := handler.database.Query(db.GetTripShares, tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	shares := []TripShare{}
+
+	for rows.Next() {
+		var share TripShare
+		var expires sql.NullTime
+		var created time.Time
+
+		deferredErr = rows.Scan(&share.ShareID, &expires, &created)
+		if deferredErr != nil {
+			return
+		}
+
+		if expires.Valid {
+			share.Expires = expires.Time.Format(time.RFC3339)
+		}
+
+		share.Created = created.Format(time.RFC3339)
+
+		shares = append(shares, share)
+	}
+
+	deferredErr = rows.Err()
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, shares)
+}
+
+func (handler *Handler) AddTripShareHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.ownedTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	var share TripShare
+
+	deferredErr = readBody(r, &share)
+	if deferredErr != nil {
+		return
+	}
+
+	if share.ExpiresIn < 0 || share.ExpiresIn > maxShareDays {
+		deferredErr = errorInvalidRequestData
+		return
+	}
+
+	var expires sql.NullTime
+
+	if share.ExpiresIn > 0 {
+		expires = sql.NullTime{Time: time.Now().AddDate(0, 0, share.ExpiresIn), Valid: true}
+	}
+
+	token, deferredErr := newSecretToken()
+	if deferredErr != nil {
+		return
+	}
+
+	var created time.Time
+
+	deferredErr = handler.database.QueryRow(db.AddTripShare, tripID, hashSecretToken(token), expires).Scan(&share.ShareID, &created)
+	if deferredErr != nil {
+		return
+	}
+
+	share.URL = configs.Envs.BaseURL + sharePath + token
+	share.Created = created.Format(time.RFC3339)
+
+	if expires.Valid {
+		share.Expires = expires.Time.Format(time.RFC3339)
+	}
+
+	deferredErr = writeServerResponse(w, true, share)
+}
+
+func (handler *Handler) RemoveTripShareHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := handler.ownedTripID(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	shareID, deferredErr := strconv.Atoi(r.PathValue(shareParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.database.ExecQuery(db.RemoveTripShare, shareID, tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, nil)
+}
+
+// SharedTripHandler renders the read only page of the trip of a share link, it does not need the Authorization
+// header so the link can be opened in any browser and unfurled by chat apps, unknown, revoked and expired links are not found
+func (handler *Handler) SharedTripHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	token := r.PathValue(shareTokenParamID)
+
+	var tripID int
+
+	deferredErr = handler.database.QueryRow(db.GetSharedTrip, hashSecretToken(token)).Scan(&tripID)
+	if deferredErr == sql.ErrNoRows {
+		deferredErr = nil
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if deferredErr != nil {
+		return
+	}
+
+	page, deferredErr := handler.sharedTripPage(tripID, token)
+	if deferredErr != nil {
+		return
+	}
+
+	page.URL = configs.Envs.BaseURL + sharePath + token
+
+	var body bytes.Buffer
+
+	deferredErr = handler.tmpl.ExecuteTemplate(&body, sharedTripTemplate, page)
+	if deferredErr != nil {
+		return
+	}
+
+	// the token is part of the url so it must not leak to the pages the trip links to or to search engines
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex")
+
+	_, deferredErr = w.Write(body.Bytes())
+}
+
+// SharedMediaHandler serves the photos of a shared trip for as long as its share link works, signed media urls
+// expire long before link previews are dropped from the cache of chat apps
+func (handler *Handler) SharedMediaHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+			)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+	}()
+
+	var tripID int
+
+	deferredErr = handler.database.QueryRow(db.GetSharedTrip, hashSecretToken(r.PathValue(shareTokenParamID))).Scan(&tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	// only the files of the shared trip can be read with its token
+	key := path.Clean(r.PathValue(mediaKeyParamID))
+	if !strings.HasPrefix(key, tripMediaPrefix(tripID)+"/") {
+		deferredErr = errorInvalidMediaSignature
+		return
+	}
+
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex")
+
+	deferredErr = handler.serveMedia(w, r, key, "private, max-age=3600")
+}
+
+// sharedTripPage reads the trip, its route and the journal entries with text that are shown on its shared page,
+// its photos are linked through the share token
+func (handler *Handler) sharedTripPage(tripID int, token string) (SharedTripPage, error) {
+	trip, err := handler.getTrip(tripID)
+	if err != nil {
+		return SharedTripPage{}, err
+	}
+
+	itinerary, err := handler.tripItinerary(tripID)
+	if err != nil {
+		return SharedTripPage{}, err
+	}
+
+	countryIDs := []int64{int64(trip.Country)}
+	for _, leg := range itinerary.Legs {
+		countryIDs = append(countryIDs, int64(leg.Country))
+	}
+
+	countries, err := handler.countryNames(countryIDs)
+	if err != nil {
+		return SharedTripPage{}, err
+	}
+
+	page := SharedTripPage{
+		Title:      trip.Title,
+		Dates:      dateRange(trip.StartDate, trip.EndDate),
+		Cover:      sharedMediaURL(token, trip.Cover),
+		Route:      []SharedStop{},
+		Highlights: []SharedHighlight{},
+	}
+
+	// trips without an itinerary only have the cities of the trip
+	if len(itinerary.Legs) == 0 {
+		for _, city := range trip.Cities {
+			page.Route = append(page.Route, SharedStop{Place: city + ", " + countries[trip.Country]})
+		}
+
+		if len(page.Route) == 0 {
+			page.Route = append(page.Route, SharedStop{Place: countries[trip.Country]})
+		}
+	}
+
+	for _, leg := range itinerary.Legs {
+		page.Route = append(page.Route, SharedStop{
+			Place: strings.TrimPrefix(leg.City+", "+countries[leg.Country], ", "),
+			Dates: dateRange(leg.Arrival, leg.Departure),
+		})
+	}
+
+	places := make([]string, len(page.Route))
+	for i, stop := range page.Route {
+		places[i] = stop.Place
+	}
+
+	page.Description = truncateText(page.Dates+" · "+strings.Join(places, " → "), maxSharedDescriptionLen)
+
+	entries, err := handler.journalEntries(tripID)
+	if err != nil {
+		return SharedTripPage{}, err
+	}
+
+	for _, entry := range entries {
+		if len(page.Highlights) == maxSharedHighlights {
+			break
+		}
+
+		text := strings.TrimSpace(entry.Text)
+		if text == "" {
+			continue
+		}
+
+		highlight := SharedHighlight{
+			Day:  entry.Day,
+			Text: truncateText(text, maxSharedHighlightText),
+		}
+
+		if len(entry.Media) > 0 {
+			highlight.Photo = sharedMediaURL(token, entry.Media[0].URL)
+		}
+
+		if page.Cover == "" {
+			page.Cover = highlight.Photo
+		}
+
+		page.Highlights = append(page.Highlights, highlight)
+	}
+
+	return page, nil
+}
+
+// countryNames reads the english names of the countries keyed by id
+func (handler *Handler) countryNames(countryIDs []int64) (map[int]string, error) {
+	rows, err := handler.database.Query(db.GetCountryNames, pq.Array(countryIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	names := make(map[int]string, len(countryIDs))
+
+	for rows.Next() {
+		var id int
+		var name string
+
+		err = rows.Scan(&id, &name)
+		if err != nil {
+			return nil, err
+		}
+
+		names[id] = name
+	}
+
+	return names, rows.Err()
+}
+
+// dateRange formats the first and last day of a trip or leg for the shared page
+func dateRange(start string, end string) string {
+	startDate, err := time.Parse(time.DateOnly, start)
+	if err != nil {
+		return start
+	}
+
+	endDate, err := time.Parse(time.DateOnly, end)
+	if err != nil || endDate.Equal(startDate) {
+		return startDate.Format("2 Jan 2006")
+	}
+
+	return fmt.Sprintf("%s – %s", startDate.Format("2 Jan 2006"), endDate.Format("2 Jan 2006"))
+}
+
+// absoluteURL prefixes media urls with the public address of the server, link previews need absolute urls
+func absoluteURL(url string) string {
+	if url == "" || !strings.HasPrefix(url, "/") {
+		return url
+	}
+
+	return configs.Envs.BaseURL + url
+}
+
+// sharedMediaURL converts the url of a trip photo into the absolute url it is served from through the share token
+func sharedMediaURL(token string, url string) string {
+	key, _, _ := strings.Cut(strings.TrimPrefix(url, media.URLPrefix), "?")
+	if key == "" || strings.HasPrefix(key, publicMediaPrefix) {
+		return absoluteURL(url)
+	}
+
+	return configs.Envs.BaseURL + sharePath + token + shareMediaPath + key
+}
+
+// truncateText shortens the text to at most limit bytes without cutting a character in half
+func truncateText(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+
+	cut := 0
+	for i := range text {
+		if i > limit-len("…") {
+			break
+		}
+
+		cut = i
+	}
+
+	return strings.TrimSpace(text[:cut]) + "…"
+}
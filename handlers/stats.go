@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/lib/pq"
+
+	"memtravel/cache"
+	"memtravel/db"
+	"memtravel/geo"
+	"memtravel/middleware"
+)
+
+type (
+	// YearStats is the blueprint for the travel figures of a single year
+	YearStats struct {
+		Year           int     `json:"year"`
+		TotalTrips     int     `json:"totalTrips"`
+		DaysTravelling int     `json:"daysTravelling"`
+		TotalCountries int     `json:"totalCountries"`
+		TotalDistance  float64 `json:"totalDistance"`
+		TotalCO2       float64 `json:"totalCO2"`
+	}
+
+	// ContinentStats is the blueprint for the countries visited in a single continent
+	ContinentStats struct {
+		Continent      string `json:"continent"`
+		TotalCountries int    `json:"totalCountries"`
+	}
+)
+
+var statsCache = cache.NewCache()
+
+func (handler *Handler) GetTripStatsHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	ownerID, deferredErr := ownerParam(r, userID)
+	if deferredErr != nil {
+		return
+	}
+
+	audience, visibilities, deferredErr := handler.tripLevels(userID, ownerID)
+	if deferredErr != nil {
+		return
+	}
+
+	stats, deferredErr := handler.cachedTripStats(ownerID, audience, visibilities)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, stats)
+}
+
+// cachedTripStats returns the statistics of the owner computed for the audience, they are cached for an hour at most
+// and never past midnight since only trips that already started are counted
+func (handler *Handler) cachedTripStats(ownerID int, audience string, visibilities []int64) (Stats, error) {
+	cacheKey := audienceCacheKey(ownerID, audience)
+
+	if cachedStats, ok := statsCache.Get(cacheKey); ok {
+		return cachedStats.(Stats), nil
+	}
+
+	stats, err := handler.tripStats(ownerID, visibilities)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	statsCache.Set(cacheKey, stats, untilMidnight(time.Hour))
+
+	return stats, nil
+}
+
+// tripStats computes the travel statistics of the owner out of the trips tables, only counting the visibility levels given
+func (handler *Handler) tripStats(ownerID int, visibilities []int64) (Stats, error) {
+	var stats Stats
+
+	err := handler.database.QueryRow(db.GetTripStats, ownerID, pq.Array(visibilities)).Scan(&stats.TotalTrips, &stats.DaysTravelling)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	err = handler.database.QueryRow(db.GetTripCountriesCount, ownerID, pq.Array(visibilities)).Scan(&stats.TotalCountries)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	err = handler.database.QueryRow(db.GetTripCitiesCount, ownerID, pq.Array(visibilities)).Scan(&stats.TotalCities)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	var totalCountries int
+
+	err = handler.database.QueryRow(db.CountCountries).Scan(&totalCountries)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	if totalCountries > 0 {
+		stats.WorldPercentage = math.Round(float64(stats.TotalCountries)/float64(totalCountries)*10000) / 100
+	}
+
+	rows, err := handler.database.Query(db.GetTripStatsPerYear, ownerID, pq.Array(visibilities))
+	if err != nil {
+		return Stats{}, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var year YearStats
+
+		err = rows.Scan(&year.Year, &year.TotalTrips, &year.DaysTravelling, &year.TotalCountries)
+		if err != nil {
+			return Stats{}, err
+		}
+
+		stats.PerYear = append(stats.PerYear, year)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	err = handler.tripFootprints(ownerID, visibilities, &stats)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	continentRows, err := handler.database.Query(db.GetTripStatsPerContinent, ownerID, pq.Array(visibilities))
+	if err != nil {
+		return Stats{}, err
+	}
+
+	defer continentRows.Close()
+
+	for continentRows.Next() {
+		var continent ContinentStats
+
+		err = continentRows.Scan(&continent.Continent, &continent.TotalCountries)
+		if err != nil {
+			return Stats{}, err
+		}
+
+		stats.PerContinent = append(stats.PerContinent, continent)
+	}
+
+	return stats, continentRows.Err()
+}
+
+// invalidateTripCaches removes every cached value that was computed out of the user trips,
+// changes to the places or dates of a trip must go through tripsChanged instead
+func invalidateTripCaches(userID any) {
+	deleteAudienceCache(statsCache, userID)
+	invalidateMapCache(userID)
+}
+
+// tripFootprints adds the distance and CO2 of the legs of every trip that already started to the year the trip started in
+func (handler *Handler) tripFootprints(ownerID int, visibilities []int64, stats *Stats) error {
+	rows, err := handler.database.Query(db.GetTripFootprints, ownerID, pq.Array(visibilities))
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	distances := make(map[int]float64)
+	emitted := make(map[int]float64)
+
+	previousTripID := 0
+	var previous *geo.Point
+
+	for rows.Next() {
+		var year, tripID int
+		var transport string
+		var latitude, longitude sql.NullFloat64
+
+		err = rows.Scan(&year, &tripID, &transport, &latitude, &longitude)
+		if err != nil {
+			return err
+		}
+
+		// legs are sorted by trip so the first leg of a trip does not continue the previous trip
+		if tripID != previousTripID {
+			previous = nil
+			previousTripID = tripID
+		}
+
+		current := stopPoint(latitude, longitude)
+
+		distance, co2 := handler.legFootprint(previous, current, transport)
+		distances[year] += distance
+		emitted[year] += co2
+		previous = current
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+
+	var totalDistance, totalCO2 float64
+
+	for i, year := range stats.PerYear {
+		stats.PerYear[i].TotalDistance = roundFootprint(distances[year.Year])
+		stats.PerYear[i].TotalCO2 = roundFootprint(emitted[year.Year])
+		totalDistance += distances[year.Year]
+		totalCO2 += emitted[year.Year]
+	}
+
+	stats.TotalDistance = roundFootprint(totalDistance)
+	stats.TotalCO2 = roundFootprint(totalCO2)
+
+	return nil
+}
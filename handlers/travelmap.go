@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"memtravel/cache"
+	"memtravel/db"
+	"memtravel/geo"
+	"memtravel/middleware"
+)
+
+type (
+	// VisitedCountry is the blueprint for a country visited by a user, dates are the first and last time it was visited,
+	// keys are kept short since the whole world map is sent at once
+	VisitedCountry struct {
+		ISO       string `json:"i"`
+		FirstDate string `json:"f"`
+		LastDate  string `json:"l"`
+		Trips     int    `json:"n"`
+	}
+
+	// WorldMap is the blueprint for the data used to shade a world map, Wishlist holds the iso codes
+	// of the countries in the bucket list that were not visited yet
+	WorldMap struct {
+		Visited  []VisitedCountry `json:"visited"`
+		Wishlist []string         `json:"wishlist"`
+	}
+)
+
+var (
+	errorUnsupportedExport = errors.New("export format must be geojson or kml")
+)
+
+var mapCache = cache.NewCache()
+
+func (handler *Handler) GetWorldMapHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	ownerID, deferredErr := strconv.Atoi(r.PathValue(pathParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	audience, visibilities, deferredErr := handler.tripLevels(userID, ownerID)
+	if deferredErr != nil {
+		return
+	}
+
+	cacheKey := audienceCacheKey(ownerID, audience)
+
+	if cachedMap, ok := mapCache.Get(cacheKey); ok {
+		writeServerResponse(w, true, cachedMap)
+		return
+	}
+
+	visited, deferredErr := handler.visitedCountries(ownerID, visibilities)
+	if deferredErr != nil {
+		return
+	}
+
+	worldMap := WorldMap{
+		Visited:  visited,
+		Wishlist: []string{},
+	}
+
+	// the bucket list is part of the profile so it is only shared with friends
+	if friendAudience(audience) {
+		worldMap.Wishlist, deferredErr = handler.wishlistCountries(ownerID, visibilities)
+		if deferredErr != nil {
+			return
+		}
+	}
+
+	mapCache.Set(cacheKey, worldMap, untilMidnight(time.Hour))
+
+	deferredErr = writeServerResponse(w, true, worldMap)
+}
+
+func (handler *Handler) ExportMapHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	ownerID, deferredErr := strconv.Atoi(r.PathValue(pathParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	format := r.URL.Query().Get(formatParamID)
+	if format == "" {
+		format = geo.FormatGeoJSON
+	}
+
+	if format != geo.FormatGeoJSON && format != geo.FormatKML {
+		deferredErr = errorUnsupportedExport
+		return
+	}
+
+	audience, visibilities, deferredErr := handler.tripLevels(userID, ownerID)
+	if deferredErr != nil {
+		return
+	}
+
+	// a public export only holds what anyone could see, so it is safe to share further
+	publicOnly, _ := strconv.ParseBool(r.URL.Query().Get(publicParamID))
+	if publicOnly && audience != audienceNone {
+		visibilities = audienceLevels[audiencePublic]
+	}
+
+	features, deferredErr := handler.mapFeatures(ownerID, visibilities)
+	if deferredErr != nil {
+		return
+	}
+
+	filename := fmt.Sprintf("memtravel-%d.%s", ownerID, format)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	if format == geo.FormatKML {
+		w.Header().Set("Content-Type", geo.KMLContentType)
+		deferredErr = geo.WriteKML(w, "memtravel", features)
+		return
+	}
+
+	w.Header().Set("Content-Type", geo.GeoJSONContentType)
+	deferredErr = geo.WriteGeoJSON(w, features)
+}
+
+// invalidateMapCache removes every cached world map of the user
+func invalidateMapCache(userID any) {
+	deleteAudienceCache(mapCache, userID)
+}
+
+// wishlistCountries reads the countries of the bucket list of the owner not visited yet on the trips with the given visibility levels
+func (handler *Handler) wishlistCountries(ownerID int, visibilities []int64) ([]string, error) {
+	rows, err := handler.database.Query(db.GetWishlistCountries, ownerID, pq.Array(visibilities))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	countries := []string{}
+
+	for rows.Next() {
+		var iso string
+
+		err = rows.Scan(&iso)
+		if err != nil {
+			return nil, err
+		}
+
+		countries = append(countries, iso)
+	}
+
+	return countries, rows.Err()
+}
+
+// mapFeatures builds the map of the owner, a feature for every visited country with its borders
+// and a feature for every trip placed at its journal locations or at the center of its country
+func (handler *Handler) mapFeatures(ownerID int, visibilities []int64) ([]geo.Feature, error) {
+	countries, err := handler.visitedCountries(ownerID, visibilities)
+	if err != nil {
+		return nil, err
+	}
+
+	features := make([]geo.Feature, 0, len(countries))
+
+	for _, country := range countries {
+		polygons, _ := handler.boundaries.Polygons(country.ISO)
+
+		features = append(features, geo.Feature{
+			Name: country.ISO,
+			Properties: map[string]any{
+				"kind":      "country",
+				"firstdate": country.FirstDate,
+				"lastdate":  country.LastDate,
+				"trips":     country.Trips,
+			},
+			Polygons: polygons,
+		})
+	}
+
+	locations, err := handler.mapTripLocations(ownerID, visibilities)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := handler.database.Query(db.GetMapTrips, ownerID, pq.Array(visibilities))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var tripID int
+		var title, iso string
+		var startDate, endDate time.Time
+		var cities []string
+
+		err = rows.Scan(&tripID, &title, &iso, &startDate, &endDate, pq.Array(&cities))
+		if err != nil {
+			return nil, err
+		}
+
+		points := locations[tripID]
+		if len(points) == 0 {
+			if center, ok := handler.boundaries.Center(iso); ok {
+				points = []geo.Point{center}
+			}
+		}
+
+		features = append(features, geo.Feature{
+			Name: title,
+			Properties: map[string]any{
+				"kind":      "trip",
+				"tripid":    tripID,
+				"country":   iso,
+				"cities":    strings.Join(cities, ", "),
+				"startdate": startDate.Format(time.DateOnly),
+				"enddate":   endDate.Format(time.DateOnly),
+			},
+			Points: points,
+		})
+	}
+
+	return features, rows.Err()
+}
+
+// visitedCountries reads the countries of the trips and legs that already started
+func (handler *Handler) visitedCountries(ownerID int, visibilities []int64) ([]VisitedCountry, error) {
+	rows, err := handler.database.Query(db.GetVisitedCountries, ownerID, pq.Array(visibilities))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	countries := []VisitedCountry{}
+
+	for rows.Next() {
+		var country VisitedCountry
+		var firstDate, lastDate time.Time
+
+		err = rows.Scan(&country.ISO, &firstDate, &lastDate, &country.Trips)
+		if err != nil {
+			return nil, err
+		}
+
+		country.FirstDate = firstDate.Format(time.DateOnly)
+		country.LastDate = lastDate.Format(time.DateOnly)
+
+		countries = append(countries, country)
+	}
+
+	return countries, rows.Err()
+}
+
+// mapTripLocations reads the journal locations of the trips keyed by trip id
+func (handler *Handler) mapTripLocations(ownerID int, visibilities []int64) (map[int][]geo.Point, error) {
+	rows, err := handler.database.Query(db.GetMapTripLocations, ownerID, pq.Array(visibilities))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	locations := make(map[int][]geo.Point)
+
+	for rows.Next() {
+		var tripID int
+		var point geo.Point
+
+		err = rows.Scan(&tripID, &point.Latitude, &point.Longitude)
+		if err != nil {
+			return nil, err
+		}
+
+		locations[tripID] = append(locations[tripID], point)
+	}
+
+	return locations, rows.Err()
+}
@@ -1,61 +1,244 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
 
 	"memtravel/db"
 	"memtravel/language"
 	"memtravel/middleware"
 )
 
+type (
+	// Trip is the blueprint for the trip data
+	Trip struct {
+		TripID     int      `json:"tripid,omitempty"`
+		UserID     int      `json:"userid,omitempty"`
+		Title      string   `json:"title"`
+		Country    int      `json:"country"`
+		Cities     []string `json:"cities,omitempty"`
+		StartDate  string   `json:"startdate"`
+		EndDate    string   `json:"enddate"`
+		Cover      string   `json:"cover,omitempty"`
+		Notes      string   `json:"notes,omitempty"`
+		Visibility int      `json:"visibility"`
+	}
+
+	// TripTimeline holds a page of trips and the cursor to request the next one
+	TripTimeline struct {
+		Trips  []Trip `json:"trips"`
+		Cursor string `json:"cursor,omitempty"`
+	}
+)
+
+// visibility levels are stored with every trip, new levels must be appended so stored values keep their meaning
+const (
+	tripVisibilityPrivate = iota
+	tripVisibilityFriends
+	tripVisibilityPublic
+	tripVisibilityCloseFriends
+	tripVisibilityUnlisted
+)
+
+const (
+	maxTripTitleLength = 60
+	maxTripNotesLength = 2000
+	maxTripCities      = 50
+	maxCityLength      = 60
+	tripTimelineLimit  = 20
+)
+
+var (
+	errorTripNotOwned = errors.New("trip does not belong to user")
+)
+
+var tripVisibilities = map[int]struct{}{
+	tripVisibilityPrivate:      {},
+	tripVisibilityFriends:      {},
+	tripVisibilityPublic:       {},
+	tripVisibilityCloseFriends: {},
+	tripVisibilityUnlisted:     {},
+}
+
 func (handler *Handler) AddTripHandler(w http.ResponseWriter, r *http.Request) {
 	var deferredErr error
 	defer func() {
 		if deferredErr != nil {
-			log.Printf("Error: %s", deferredErr.Error())
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 	}()
 
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	var trip Trip
+
+	// trips sent without a visibility keep the default visibility of the account
+	deferredErr = handler.database.QueryRow(db.GetTripVisibility, userID).Scan(&trip.Visibility)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = readBody(r, &trip)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = validateTrip(&trip)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.database.QueryRow(
+		db.AddTrip,
+		userID,
+		trip.Title,
+		trip.Country,
+		pq.Array(trip.Cities),
+		trip.StartDate,
+		trip.EndDate,
+		trip.Notes,
+		trip.Visibility,
+	).Scan(&trip.TripID)
+	if deferredErr != nil {
+		return
+	}
+
+	handler.tripsChanged(userID)
+
+	deferredErr = writeServerResponse(w, true, trip)
 }
 
 func (handler *Handler) GetUpcomingTripsHandler(w http.ResponseWriter, r *http.Request) {
 	var deferredErr error
 	defer func() {
 		if deferredErr != nil {
-			log.Printf("Error: %s", deferredErr.Error())
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 	}()
 
+	timeline, deferredErr := handler.tripTimeline(r, db.GetUpcomingTrips, db.GetUpcomingTripsAfter)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, timeline)
 }
 
 func (handler *Handler) GetPreviousTripsHandler(w http.ResponseWriter, r *http.Request) {
 	var deferredErr error
 	defer func() {
 		if deferredErr != nil {
-			log.Printf("Error: %s", deferredErr.Error())
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 	}()
 
+	timeline, deferredErr := handler.tripTimeline(r, db.GetPreviousTrips, db.GetPreviousTripsAfter)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, timeline)
 }
 
 func (handler *Handler) EditTripHandler(w http.ResponseWriter, r *http.Request) {
 	var deferredErr error
 	defer func() {
 		if deferredErr != nil {
-			log.Printf("Error: %s", deferredErr.Error())
+			log.Printf("Error: [%s], context_id: [%s], user_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+				r.Context().Value(middleware.AuthUserID),
+			)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 	}()
 
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	tripID, deferredErr := strconv.Atoi(r.PathValue(pathParamID))
+	if deferredErr != nil {
+		return
+	}
+
+	owned, deferredErr := handler.tripBelongsToUser(userID, tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	if !owned {
+		deferredErr = errorTripNotOwned
+		return
+	}
+
+	var trip Trip
+
+	deferredErr = readBody(r, &trip)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = validateTrip(&trip)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = handler.database.ExecQuery(
+		db.EditTrip,
+		trip.Title,
+		trip.Country,
+		pq.Array(trip.Cities),
+		trip.StartDate,
+		trip.EndDate,
+		trip.Notes,
+		trip.Visibility,
+		tripID,
+		userID,
+	)
+	if deferredErr != nil {
+		return
+	}
+
+	// trips with an itinerary keep the dates derived from their legs
+	_, deferredErr = handler.database.Exec(db.SyncTripWithLegs, tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	handler.tripsChanged(userID)
+
+	trip, deferredErr = handler.getTrip(tripID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, trip)
 }
 
 func (handler *Handler) RemoveTripHandler(w http.ResponseWriter, r *http.Request) {
@@ -76,7 +259,7 @@ func (handler *Handler) RemoveTripHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	tripID := r.PathValue("id")
+	tripID := r.PathValue(pathParamID)
 	if tripID == "" {
 		deferredErr = errorPathValueNotFound
 		return
@@ -87,10 +270,213 @@ func (handler *Handler) RemoveTripHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// the keys are read before the trip is gone, the files are only removed once the trip was removed
+	mediaKeys, deferredErr := handler.tripMediaKeys(tripIDInt)
+	if deferredErr != nil {
+		return
+	}
+
 	deferredErr = handler.database.ExecQuery(db.RemoveTrip, tripIDInt, userID)
 	if deferredErr != nil {
 		return
 	}
 
+	handler.tripsChanged(userID)
+	handler.removeMediaFiles(mediaKeys)
+
 	deferredErr = writeServerResponse(w, true, nil)
 }
+
+// tripTimeline reads a page of the trips of the user, or of the user in the query that are listed to the caller,
+// splitting them at the current date in the timezone sent by the client.
+// firstPageQuery is used when no cursor is sent, nextPageQuery continues after the (startdate, tripid) in the cursor
+func (handler *Handler) tripTimeline(r *http.Request, firstPageQuery string, nextPageQuery string) (TripTimeline, error) {
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	ownerID, err := ownerParam(r, userID)
+	if err != nil {
+		return TripTimeline{}, err
+	}
+
+	_, visibilities, err := handler.tripLevels(userID, ownerID)
+	if err != nil {
+		return TripTimeline{}, err
+	}
+
+	location := time.UTC
+
+	timezone := r.URL.Query().Get(timezoneParamID)
+	if timezone != "" {
+		location, err = time.LoadLocation(timezone)
+		if err != nil {
+			return TripTimeline{}, err
+		}
+	}
+
+	today := time.Now().In(location).Format(time.DateOnly)
+
+	var rows *sql.Rows
+
+	cursor := r.URL.Query().Get(cursorParamID)
+	if cursor == "" {
+		rows, err = handler.database.Query(firstPageQuery, ownerID, today, tripTimelineLimit, pq.Array(visibilities))
+	} else {
+		cursorDate, cursorTripID, cursorErr := decodeCursor(cursor)
+		if cursorErr != nil {
+			return TripTimeline{}, cursorErr
+		}
+
+		rows, err = handler.database.Query(nextPageQuery, ownerID, today, tripTimelineLimit, pq.Array(visibilities), cursorDate, cursorTripID)
+	}
+
+	if err != nil {
+		return TripTimeline{}, err
+	}
+
+	defer rows.Close()
+
+	timeline := TripTimeline{
+		Trips: []Trip{},
+	}
+
+	for rows.Next() {
+		var trip Trip
+
+		err = scanTrip(rows, &trip)
+		if err != nil {
+			return TripTimeline{}, err
+		}
+
+		timeline.Trips = append(timeline.Trips, trip)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return TripTimeline{}, err
+	}
+
+	if len(timeline.Trips) == tripTimelineLimit {
+		last := timeline.Trips[len(timeline.Trips)-1]
+		timeline.Cursor = encodeCursor(last.StartDate, last.TripID)
+	}
+
+	return timeline, nil
+}
+
+// tripBelongsToUser checks if the trip with the given id is owned by the user
+func (handler *Handler) tripBelongsToUser(userID any, tripID int) (bool, error) {
+	rows, err := handler.database.Query(db.TripBelongsToUser, userID, tripID)
+	if err != nil {
+		return false, err
+	}
+
+	defer rows.Close()
+
+	return rows.Next(), rows.Err()
+}
+
+// tripsChanged must be called whenever a trip of the user or one of its legs is added, changed or removed,
+// badges are refreshed on the spot and a failure is only logged since the trip change was already stored
+func (handler *Handler) tripsChanged(userID any) {
+	invalidateTripCaches(userID)
+
+	ownerID, err := strconv.Atoi(fmt.Sprint(userID))
+	if err == nil {
+		err = handler.RefreshBadges(ownerID, time.Now())
+	}
+
+	if err != nil {
+		log.Printf("Error: [%s], user_id: [%s]", err.Error(), fmt.Sprint(userID))
+	}
+}
+
+// getTrip reads a single trip by its id
+func (handler *Handler) getTrip(tripID int) (Trip, error) {
+	var trip Trip
+
+	err := scanTrip(handler.database.QueryRow(db.GetTrip, tripID), &trip)
+	if err != nil {
+		return Trip{}, err
+	}
+
+	return trip, nil
+}
+
+// scanTrip reads a trip row in the column order used by db.GetTrip
+func scanTrip(row interface{ Scan(...any) error }, trip *Trip) error {
+	var startDate, endDate time.Time
+
+	err := row.Scan(
+		&trip.TripID,
+		&trip.UserID,
+		&trip.Title,
+		&trip.Country,
+		pq.Array(&trip.Cities),
+		&startDate,
+		&endDate,
+		&trip.Cover,
+		&trip.Notes,
+		&trip.Visibility,
+	)
+	if err != nil {
+		return err
+	}
+
+	trip.StartDate = startDate.Format(time.DateOnly)
+	trip.EndDate = endDate.Format(time.DateOnly)
+	trip.Cover = mediaURL(trip.Cover)
+
+	return nil
+}
+
+// validateTrip checks the trip request data and normalises it before it is stored
+func validateTrip(trip *Trip) error {
+	trip.Title = strings.TrimSpace(trip.Title)
+	if trip.Title == "" || len(trip.Title) > maxTripTitleLength {
+		return errorInvalidRequestData
+	}
+
+	if trip.Country <= 0 {
+		return errorInvalidRequestData
+	}
+
+	// covers are only set through the media upload endpoint
+	trip.Cover = ""
+
+	if len(trip.Notes) > maxTripNotesLength {
+		return errorInvalidRequestData
+	}
+
+	if _, ok := tripVisibilities[trip.Visibility]; !ok {
+		return errorInvalidRequestData
+	}
+
+	if len(trip.Cities) > maxTripCities {
+		return errorInvalidRequestData
+	}
+
+	for i, city := range trip.Cities {
+		city = strings.TrimSpace(city)
+		if city == "" || len(city) > maxCityLength {
+			return errorInvalidRequestData
+		}
+
+		trip.Cities[i] = city
+	}
+
+	startDate, err := time.Parse(time.DateOnly, trip.StartDate)
+	if err != nil {
+		return err
+	}
+
+	endDate, err := time.Parse(time.DateOnly, trip.EndDate)
+	if err != nil {
+		return err
+	}
+
+	if endDate.Before(startDate) {
+		return errorInvalidRequestData
+	}
+
+	return nil
+}
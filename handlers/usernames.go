@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"memtravel/db"
+	"memtravel/language"
+	"memtravel/middleware"
+)
+
+// UsernameAvailability is the blueprint for the answer to a username check, the suggestions are free usernames
+// close to the one asked for and are only sent when it is taken
+type UsernameAvailability struct {
+	Username    string   `json:"username"`
+	Available   bool     `json:"available"`
+	Message     string   `json:"message,omitempty"`
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+const (
+	maxUsernameChanges     = 3
+	maxUsernameLength      = 30
+	maxUsernameSuggestions = 3
+	usernameGracePeriod    = 30 * 24 * time.Hour
+)
+
+// usernameSuffixes are added to a taken username to suggest free ones, random numbers are added after them
+var usernameSuffixes = []string{"_travels", "_trips", ".abroad"}
+
+// usernameFormat is the format of the usernames chosen by users, the generated ones may be longer
+var usernameFormat = regexp.MustCompile(`^@[A-Za-z0-9_.]{3,30}$`)
+
+// UsernameAvailableHandler checks if a username can be chosen at sign up, it does not need the Authorization header
+func (handler *Handler) UsernameAvailableHandler(w http.ResponseWriter, r *http.Request) {
+	var deferredErr error
+	defer func() {
+		if deferredErr != nil {
+			log.Printf("Error: [%s], context_id: [%s]",
+				deferredErr.Error(),
+				r.Context().Value(middleware.RequestContextID),
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}()
+
+	languageID := r.URL.Query().Get(languageParamID)
+	if !language.SupportedLanguage(languageID) {
+		deferredErr = errorLanguageID
+		return
+	}
+
+	username := normaliseUsername(r.URL.Query().Get(usernameParamID))
+	if !usernameFormat.MatchString(username) {
+		deferredErr = errorInvalidRequestData
+		return
+	}
+
+	availability, deferredErr := handler.usernameAvailability(username, 0, languageID)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, availability)
+}
+
+// normaliseUsername trims the username and adds the @ users may leave out
+func normaliseUsername(username string) string {
+	username = strings.TrimSpace(username)
+	if username != "" && !strings.HasPrefix(username, "@") {
+		username = "@" + username
+	}
+
+	return username
+}
+
+// usernameTaken checks if the username is used by anyone other than the user, ignoring case, handles that were
+// given up during the grace period are still taken so they keep pointing to the user that had them
+func (handler *Handler) usernameTaken(username string, userID any) (bool, error) {
+	var taken bool
+
+	err := handler.database.QueryRow(db.CheckUsernameTaken, username, userID, time.Now().Add(-usernameGracePeriod)).Scan(&taken)
+
+	return taken, err
+}
+
+// renamedUsername returns the current username of the user that gave up the username during the grace period
+func (handler *Handler) renamedUsername(username string) (string, error) {
+	var current string
+
+	err := handler.database.QueryRow(db.GetRenamedUsername, username, time.Now().Add(-usernameGracePeriod)).Scan(&current)
+
+	return current, err
+}
+
+// searchCacheKey is the key of a search of the user in the userCache
+func searchCacheKey(userID any, query string) string {
+	return fmt.Sprint(userID) + ":" + query
+}
+
+// invalidateUserSearches removes the cached searches the user is listed in and the ones the new username
+// would now be found by
+func invalidateUserSearches(userID any, username string) {
+	userCache.DeleteFunc(func(key string, value any) bool {
+		_, query, _ := strings.Cut(key, ":")
+		if strings.Contains(strings.ToLower(username), query) {
+			return true
+		}
+
+		results, ok := value.(SearchUserResult)
+		if !ok {
+			return false
+		}
+
+		return slices.ContainsFunc(results.Users, func(user User) bool {
+			return strconv.Itoa(user.UserID) == fmt.Sprint(userID)
+		})
+	})
+}
+
+// usernameAvailability checks if the user can take the username and suggests free ones when it cannot
+func (handler *Handler) usernameAvailability(username string, userID any, languageID string) (UsernameAvailability, error) {
+	availability := UsernameAvailability{Username: username}
+
+	taken, err := handler.usernameTaken(username, userID)
+	if err != nil {
+		return availability, err
+	}
+
+	if !taken {
+		availability.Available = true
+		return availability, nil
+	}
+
+	availability.Message = language.GetTranslation(languageID, language.UsernameTaken)
+
+	availability.Suggestions, err = handler.usernameSuggestions(username)
+
+	return availability, err
+}
+
+// usernameSuggestions returns free usernames made out of the username with a suffix added
+func (handler *Handler) usernameSuggestions(username string) ([]string, error) {
+	rows, err := handler.database.Query(
+		db.GetFreeUsernames,
+		pq.Array(usernameCandidates(username)),
+		time.Now().Add(-usernameGracePeriod),
+		maxUsernameSuggestions,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	suggestions := []string{}
+
+	for rows.Next() {
+		var suggestion string
+
+		err = rows.Scan(&suggestion)
+		if err != nil {
+			return nil, err
+		}
+
+		suggestions = append(suggestions, suggestion)
+	}
+
+	return suggestions, rows.Err()
+}
+
+// usernameCandidates adds the suffixes and a few random numbers to the username, cutting it so they stay valid
+func usernameCandidates(username string) []string {
+	base := strings.TrimPrefix(username, "@")
+
+	suffixes := append([]string{}, usernameSuffixes...)
+	for i := 0; i < 6; i++ {
+		suffixes = append(suffixes, strconv.Itoa(10+rand.Intn(9990)))
+	}
+
+	candidates := make([]string, 0, len(suffixes))
+
+	for _, suffix := range suffixes {
+		cut := base
+		if len(cut)+len(suffix) > maxUsernameLength {
+			cut = cut[:maxUsernameLength-len(suffix)]
+		}
+
+		candidates = append(candidates, "@"+cut+suffix)
+	}
+
+	return candidates
+}
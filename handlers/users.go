@@ -1,38 +1,69 @@
 package handlers
 
 import (
+	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"memtravel/cache"
 	"memtravel/db"
+	"memtravel/language"
 	"memtravel/middleware"
 )
 
 type (
 	PinnedTrip struct {
-		TripID    int
-		Cover     string
-		Country   string
-		StartDate string
+		TripID    int    `json:"tripid"`
+		Cover     string `json:"cover,omitempty"`
+		Country   string `json:"country"`
+		StartDate string `json:"startdate"`
 	}
 
 	Stats struct {
-		TotalTrips     int
-		DaysTravelling int
-		TotalCountries int
-		TotalCities    int
+		TotalTrips      int              `json:"totalTrips"`
+		DaysTravelling  int              `json:"daysTravelling"`
+		TotalCountries  int              `json:"totalCountries"`
+		TotalCities     int              `json:"totalCities"`
+		WorldPercentage float64          `json:"worldPercentage"`
+		TotalDistance   float64          `json:"totalDistance"`
+		TotalCO2        float64          `json:"totalCO2"`
+		PerYear         []YearStats      `json:"perYear,omitempty"`
+		PerContinent    []ContinentStats `json:"perContinent,omitempty"`
 	}
 
 	SearchUserResult struct {
 		Users []User `json:"user"`
 		Page  int    `json:"page"`
 	}
+
+	// ProfileEdit is the blueprint for the profile details a user can change, every field is sent and replaces the current value
+	ProfileEdit struct {
+		FullName string `json:"fullname"`
+		Bio      string `json:"bio"`
+		ShowDoB  bool   `json:"showdob"`
+		Country  int    `json:"country"`
+		Username string `json:"username"`
+	}
+)
+
+const (
+	maxFullNameLength = 45
+	maxBioLength      = 160
 )
 
+const (
+	searchPageSize            = 20
+	searchMinSimilarity       = 0.3
+	searchFriendBoost         = 0.5
+	searchFriendOfFriendBoost = 0.25
+)
+
+// userCache holds the first page of the searches of every user, the ranking depends on who is searching
 var userCache = cache.NewCache()
 
 func (handler *Handler) SearchUsersHandler(w http.ResponseWriter, r *http.Request) {
@@ -51,14 +82,13 @@ func (handler *Handler) SearchUsersHandler(w http.ResponseWriter, r *http.Reques
 
 	userID := r.Context().Value(middleware.AuthUserID)
 
-	searchQuery := r.URL.Query().Get("query")
+	searchQuery := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("query")))
 	if len(searchQuery) < 2 {
 		writeServerResponse(w, true, []User{})
 		return
 	}
 
 	page := 1
-	limit := 20
 
 	p := r.URL.Query().Get("page")
 	if p != "" {
@@ -68,16 +98,27 @@ func (handler *Handler) SearchUsersHandler(w http.ResponseWriter, r *http.Reques
 		}
 	}
 
-	offset := (page - 1) * limit
+	offset := (page - 1) * searchPageSize
+	cacheKey := searchCacheKey(userID, searchQuery)
 
 	if page == 1 {
-		if cachedSearch, ok := userCache.Get(searchQuery); ok {
+		if cachedSearch, ok := userCache.Get(cacheKey); ok {
 			writeServerResponse(w, true, cachedSearch)
 			return
 		}
 	}
 
-	rows, deferredErr := handler.database.Query(db.SearchUser, userID, searchQuery, offset)
+	// full names of private accounts are only matched for their friends so they cannot be found by their real name
+	rows, deferredErr := handler.database.Query(
+		db.SearchUser,
+		userID,
+		searchQuery,
+		offset,
+		searchMinSimilarity,
+		searchFriendBoost,
+		searchFriendOfFriendBoost,
+		searchPageSize,
+	)
 	if deferredErr != nil {
 		deferredErr = fmt.Errorf("failed to query users: %v", deferredErr)
 		return
@@ -92,12 +133,14 @@ func (handler *Handler) SearchUsersHandler(w http.ResponseWriter, r *http.Reques
 	for rows.Next() {
 		var user User
 
-		deferredErr = rows.Scan(&user.FullName, &user.Username, &user.ProfilePicture)
+		deferredErr = rows.Scan(&user.UserID, &user.FullName, &user.Username, &user.ProfilePicture, &user.IsPrivate, &user.IsFriend)
 		if deferredErr != nil {
 			deferredErr = fmt.Errorf("failed to scan user row: %v", deferredErr)
 			return
 		}
 
+		user.ProfilePicture = mediaURL(user.ProfilePicture)
+
 		results.Users = append(results.Users, user)
 	}
 
@@ -108,7 +151,7 @@ func (handler *Handler) SearchUsersHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	if page == 1 {
-		userCache.Set(searchQuery, results, 2*time.Minute)
+		userCache.Set(cacheKey, results, 2*time.Minute)
 	}
 
 	deferredErr = writeServerResponse(w, true, results)
@@ -128,6 +171,124 @@ func (handler *Handler) GetUserHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	var row *sql.Row
+
+	username := strings.TrimSpace(r.URL.Query().Get(usernameParamID))
+	if username != "" {
+		row = handler.database.QueryRow(db.GetUserProfileByUsername, username)
+	} else {
+		targetID, err := ownerParam(r, userID)
+		if err != nil {
+			deferredErr = err
+			return
+		}
+
+		row = handler.database.QueryRow(db.GetUserProfile, targetID)
+	}
+
+	var user User
+	var dob time.Time
+	var showDoB bool
+
+	deferredErr = row.Scan(
+		&user.UserID,
+		&user.Username,
+		&user.FullName,
+		&user.ProfilePicture,
+		&user.Country,
+		&user.Bio,
+		&user.IsPrivate,
+		&dob,
+		&showDoB,
+		&user.TotalFriends,
+	)
+	if deferredErr == sql.ErrNoRows && username != "" {
+		// old handles keep pointing to the user that changed them during the grace period
+		current, err := handler.renamedUsername(username)
+		if err == nil {
+			deferredErr = nil
+			http.Redirect(w, r, r.URL.Path+"?"+usernameParamID+"="+url.QueryEscape(current), http.StatusFound)
+			return
+		}
+
+		if err != sql.ErrNoRows {
+			deferredErr = err
+			return
+		}
+	}
+
+	if deferredErr == sql.ErrNoRows {
+		deferredErr = nil
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if deferredErr != nil {
+		return
+	}
+
+	user.ProfilePicture = mediaURL(user.ProfilePicture)
+
+	owner := strconv.Itoa(user.UserID) == fmt.Sprint(userID)
+
+	if !owner {
+		var friendsSince time.Time
+
+		deferredErr = handler.database.QueryRow(db.GetFriendsSince, userID, user.UserID).Scan(&friendsSince)
+		if deferredErr != nil && deferredErr != sql.ErrNoRows {
+			return
+		}
+
+		user.IsFriend = deferredErr == nil
+		deferredErr = nil
+
+		if user.IsFriend {
+			user.FriendsSince = friendsSince.Format(time.DateOnly)
+		}
+	}
+
+	audience, visibilities, deferredErr := handler.tripLevels(userID, user.UserID)
+	if deferredErr != nil {
+		return
+	}
+
+	// private accounts only show a card to anyone that is not a friend
+	if audience == audienceNone {
+		deferredErr = writeServerResponse(w, true, User{
+			UserID:         user.UserID,
+			Username:       user.Username,
+			FullName:       user.FullName,
+			ProfilePicture: user.ProfilePicture,
+			TotalFriends:   user.TotalFriends,
+			IsPrivate:      true,
+		})
+		return
+	}
+
+	if showDoB || owner {
+		user.DoB = dob.Format(time.DateOnly)
+	}
+
+	user.PinnedTrips, deferredErr = handler.pinnedTrips(user.UserID, visibilities)
+	if deferredErr != nil {
+		return
+	}
+
+	stats, deferredErr := handler.cachedTripStats(user.UserID, audience, visibilities)
+	if deferredErr != nil {
+		return
+	}
+
+	user.Stats = []Stats{stats}
+
+	user.Badges, deferredErr = handler.userBadges(user.UserID, audience, visibilities)
+	if deferredErr != nil {
+		return
+	}
+
+	deferredErr = writeServerResponse(w, true, user)
 }
 
 func (handler *Handler) UserEditHandler(w http.ResponseWriter, r *http.Request) {
@@ -144,5 +305,95 @@ func (handler *Handler) UserEditHandler(w http.ResponseWriter, r *http.Request)
 		}
 	}()
 
-	// TODO
+	userID := r.Context().Value(middleware.AuthUserID)
+
+	languageID := r.URL.Query().Get(languageParamID)
+	if !language.SupportedLanguage(languageID) {
+		deferredErr = errorLanguageID
+		return
+	}
+
+	var profile ProfileEdit
+
+	deferredErr = readBody(r, &profile)
+	if deferredErr != nil {
+		return
+	}
+
+	profile.FullName = strings.TrimSpace(profile.FullName)
+	profile.Bio = strings.TrimSpace(profile.Bio)
+	profile.Username = normaliseUsername(profile.Username)
+
+	if profile.FullName == "" || len(profile.FullName) >= maxFullNameLength {
+		deferredErr = errorInvalidRequestData
+		return
+	}
+
+	if len(profile.Bio) > maxBioLength || profile.Country <= 0 {
+		deferredErr = errorInvalidRequestData
+		return
+	}
+
+	var currentUsername string
+
+	deferredErr = handler.database.QueryRow(db.GetUsername, userID).Scan(&currentUsername)
+	if deferredErr != nil {
+		return
+	}
+
+	transactions := []db.Transaction{
+		{
+			Query:  db.UpdateUserProfile,
+			Params: []any{profile.FullName, profile.Bio, profile.Country, profile.Username, userID},
+		},
+		{
+			Query:  db.UpdateShowDoB,
+			Params: []any{profile.ShowDoB, userID},
+		},
+	}
+
+	if profile.Username != currentUsername {
+		if !usernameFormat.MatchString(profile.Username) {
+			deferredErr = errorInvalidRequestData
+			return
+		}
+
+		var changes int
+
+		deferredErr = handler.database.QueryRow(db.CountUsernameChanges, userID, time.Now().AddDate(-1, 0, 0)).Scan(&changes)
+		if deferredErr != nil {
+			return
+		}
+
+		if changes >= maxUsernameChanges {
+			deferredErr = writeServerResponse(w, false, language.GetTranslation(languageID, language.UsernameChangeLimit))
+			return
+		}
+
+		availability, err := handler.usernameAvailability(profile.Username, userID, languageID)
+		if err != nil {
+			deferredErr = err
+			return
+		}
+
+		if !availability.Available {
+			deferredErr = writeServerResponse(w, false, availability)
+			return
+		}
+
+		transactions = append(transactions, db.Transaction{
+			Query:  db.AddUsernameHistory,
+			Params: []any{userID, currentUsername},
+		})
+	}
+
+	deferredErr = handler.database.ExecTransaction(transactions)
+	if deferredErr != nil {
+		return
+	}
+
+	// searches show the full name next to the username so they are stale even if only the name changed
+	invalidateUserSearches(userID, profile.Username)
+
+	deferredErr = writeServerResponse(w, true, "")
 }
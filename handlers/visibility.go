@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"memtravel/cache"
+	"memtravel/db"
+)
+
+// audiences are the relationships a viewer can have with the owner of a trip
+const (
+	audienceOwner        = "owner"
+	audienceCloseFriends = "closefriends"
+	audienceFriends      = "friends"
+	audiencePublic       = "public"
+	audienceNone         = "none"
+)
+
+var (
+	errorTripNotVisible = errors.New("trip is not visible to user")
+)
+
+// audienceLevels are the visibility levels listed to each audience, unlisted trips are only listed to their owner,
+// anyone else can only read them through a share link, private accounts list nothing to anyone that is not a friend
+var audienceLevels = map[string][]int64{
+	audienceOwner: {
+		tripVisibilityPrivate,
+		tripVisibilityCloseFriends,
+		tripVisibilityFriends,
+		tripVisibilityPublic,
+		tripVisibilityUnlisted,
+	},
+	audienceCloseFriends: {tripVisibilityCloseFriends, tripVisibilityFriends, tripVisibilityPublic},
+	audienceFriends:      {tripVisibilityFriends, tripVisibilityPublic},
+	audiencePublic:       {tripVisibilityPublic},
+	audienceNone:         {},
+}
+
+// tripAudience returns the audience the user belongs to for the trips of the owner, it is the only check needed
+// before reading anything of someone else since it obeys both the visibility of the trips and the account privacy
+func (handler *Handler) tripAudience(userID any, ownerID int) (string, error) {
+	if strconv.Itoa(ownerID) == fmt.Sprint(userID) {
+		return audienceOwner, nil
+	}
+
+	var closeFriend bool
+
+	err := handler.database.QueryRow(db.GetFriendship, userID, ownerID).Scan(&closeFriend)
+	if err == sql.ErrNoRows {
+		return handler.strangerAudience(ownerID)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if closeFriend {
+		return audienceCloseFriends, nil
+	}
+
+	return audienceFriends, nil
+}
+
+// strangerAudience is the audience of anyone that is not a friend of the owner, nothing is listed to them
+// when the account of the owner is private or inactive
+func (handler *Handler) strangerAudience(ownerID int) (string, error) {
+	var private bool
+
+	err := handler.database.QueryRow(db.GetUserPrivacy, ownerID).Scan(&private)
+	if err == sql.ErrNoRows {
+		return audienceNone, nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if private {
+		return audienceNone, nil
+	}
+
+	return audiencePublic, nil
+}
+
+// tripLevels returns the visibility levels of the trips of the owner the user is allowed to list
+func (handler *Handler) tripLevels(userID any, ownerID int) (string, []int64, error) {
+	audience, err := handler.tripAudience(userID, ownerID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return audience, audienceLevels[audience], nil
+}
+
+// friendAudience checks if the audience is the owner or one of their friends, the parts of the profile
+// that are not trips, like the bucket list, are only shared with them
+func friendAudience(audience string) bool {
+	return audience == audienceOwner || audience == audienceCloseFriends || audience == audienceFriends
+}
+
+// canViewTrip checks if the user is allowed to read the trip, either because its visibility level is listed
+// to the audience of the user or because the user was added to the trip
+func (handler *Handler) canViewTrip(userID any, trip Trip) (bool, error) {
+	_, levels, err := handler.tripLevels(userID, trip.UserID)
+	if err != nil {
+		return false, err
+	}
+
+	if slices.Contains(levels, int64(trip.Visibility)) {
+		return true, nil
+	}
+
+	var participant bool
+
+	err = handler.database.QueryRow(db.IsTripParticipant, trip.TripID, userID).Scan(&participant)
+
+	return participant, err
+}
+
+// viewableTripID reads the trip id from the path and makes sure the user is allowed to read it
+func (handler *Handler) viewableTripID(r *http.Request, userID any) (int, error) {
+	tripID, err := strconv.Atoi(r.PathValue(pathParamID))
+	if err != nil {
+		return 0, err
+	}
+
+	trip, err := handler.getTrip(tripID)
+	if err != nil {
+		return 0, err
+	}
+
+	visible, err := handler.canViewTrip(userID, trip)
+	if err != nil {
+		return 0, err
+	}
+
+	if !visible {
+		return 0, errorTripNotVisible
+	}
+
+	return tripID, nil
+}
+
+// ownerParam reads the owner of the trips to list from the query, the user itself when it is not sent
+func ownerParam(r *http.Request, userID any) (int, error) {
+	owner := r.URL.Query().Get(userParamID)
+	if owner == "" {
+		owner = fmt.Sprint(userID)
+	}
+
+	return strconv.Atoi(owner)
+}
+
+// audienceCacheKey is the key of a value computed out of the trips of the owner listed to the audience
+func audienceCacheKey(ownerID any, audience string) string {
+	return fmt.Sprint(ownerID) + ":" + audience
+}
+
+// deleteAudienceCache removes the values of the owner cached for every audience
+func deleteAudienceCache(c *cache.Cache, ownerID any) {
+	for audience := range audienceLevels {
+		c.Delete(audienceCacheKey(ownerID, audience))
+	}
+}
@@ -0,0 +1,130 @@
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// ContentType is the media type of an iCalendar file
+	ContentType = "text/calendar; charset=utf-8"
+
+	productID     = "-//memtravel//trips//EN"
+	dateLayout    = "20060102"
+	utcLayout     = "20060102T150405Z"
+	maxLineLength = 75
+)
+
+// Event is a single all-day event, End is the last day of the event and not the day after it
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	End         time.Time
+}
+
+// Calendar is a named list of events
+type Calendar struct {
+	Name   string
+	Events []Event
+}
+
+// Encode writes the calendar in the iCalendar format (RFC 5545), stamp is used as the DTSTAMP of every event
+func Encode(w io.Writer, calendar Calendar, stamp time.Time) error {
+	writer := bufio.NewWriter(w)
+
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:" + productID,
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+	}
+
+	if calendar.Name != "" {
+		lines = append(lines, "X-WR-CALNAME:"+escape(calendar.Name))
+	}
+
+	for _, event := range calendar.Events {
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+escape(event.UID),
+			"DTSTAMP:"+stamp.UTC().Format(utcLayout),
+			"DTSTART;VALUE=DATE:"+event.Start.Format(dateLayout),
+			// the end date of an all-day event is exclusive
+			"DTEND;VALUE=DATE:"+event.End.AddDate(0, 0, 1).Format(dateLayout),
+			"SUMMARY:"+escape(event.Summary),
+			"TRANSP:TRANSPARENT",
+		)
+
+		if event.Description != "" {
+			lines = append(lines, "DESCRIPTION:"+escape(event.Description))
+		}
+
+		if event.Location != "" {
+			lines = append(lines, "LOCATION:"+escape(event.Location))
+		}
+
+		lines = append(lines, "END:VEVENT")
+	}
+
+	lines = append(lines, "END:VCALENDAR")
+
+	for _, line := range lines {
+		_, err := writer.WriteString(fold(line))
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
+
+// escape escapes the characters that have a meaning inside a text value
+func escape(value string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	)
+
+	return replacer.Replace(value)
+}
+
+// fold splits a content line into lines of at most 75 octets, continuation lines start with a space,
+// lines are never split in the middle of a multi-byte character
+func fold(line string) string {
+	var builder strings.Builder
+
+	limit := maxLineLength
+
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+
+		builder.WriteString(line[:cut])
+		builder.WriteString("\r\n ")
+
+		line = line[cut:]
+		// the leading space of a continuation line counts towards its length
+		limit = maxLineLength - 1
+	}
+
+	builder.WriteString(line)
+	builder.WriteString("\r\n")
+
+	return builder.String()
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
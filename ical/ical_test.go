@@ -0,0 +1,62 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncode_AllDayEventEndsTheDayAfter(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := Encode(&buf, Calendar{
+		Name: "Trips",
+		Events: []Event{{
+			UID:     "trip-1@memtravel",
+			Summary: "Lisbon, Porto; summer",
+			Start:   time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+			End:     time.Date(2024, 7, 10, 0, 0, 0, 0, time.UTC),
+		}},
+	}, time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := buf.String()
+
+	for _, expected := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"DTSTAMP:20240601T120000Z\r\n",
+		"DTSTART;VALUE=DATE:20240701\r\n",
+		"DTEND;VALUE=DATE:20240711\r\n",
+		`SUMMARY:Lisbon\, Porto\; summer` + "\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got %q", expected, output)
+		}
+	}
+}
+
+func TestFold_LongLines(t *testing.T) {
+	folded := fold("DESCRIPTION:" + strings.Repeat("é", 100))
+
+	for _, line := range strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n") {
+		if len(line) > maxLineLength {
+			t.Errorf("Expected lines of at most %d octets, got %d", maxLineLength, len(line))
+		}
+	}
+
+	unfolded := strings.ReplaceAll(strings.TrimSuffix(folded, "\r\n"), "\r\n ", "")
+	if unfolded != "DESCRIPTION:"+strings.Repeat("é", 100) {
+		t.Errorf("Expected unfolded line to match the original, got %q", unfolded)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	escaped := escape("a\\b;c,d\ne")
+	if escaped != `a\\b\;c\,d\ne` {
+		t.Errorf("Unexpected escaped value %q", escaped)
+	}
+}
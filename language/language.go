@@ -13,6 +13,8 @@ const (
 	AccountExisting         = "AccountExisting"
 	AccountNotExisting      = "AccountNotExisting"
 	Welcome                 = "Welcome"
+	UsernameTaken           = "UsernameTaken"
+	UsernameChangeLimit     = "UsernameChangeLimit"
 
 	EnglishID    = "1"
 	PortugueseID = "2"
@@ -41,6 +43,8 @@ var en = map[string]string{
 	AccountNotExisting:      "Account does not exist",
 	Welcome:                 "Memtravel welcomes you",
 	BlockedLogin:            "Your account is currently locked",
+	UsernameTaken:           "This username is already taken",
+	UsernameChangeLimit:     "You have changed your username too many times this year",
 }
 
 var pt = map[string]string{
@@ -56,6 +60,8 @@ var pt = map[string]string{
 	AccountNotExisting:      "Esta conta nao exist.",
 	Welcome:                 "Bem-vindo a Memtravel",
 	BlockedLogin:            "A sua conta está bloqueada",
+	UsernameTaken:           "Este nome de utilizador já está em uso",
+	UsernameChangeLimit:     "Já mudou o seu nome de utilizador demasiadas vezes este ano",
 }
 
 var fr = map[string]string{
@@ -71,6 +77,8 @@ var fr = map[string]string{
 	AccountNotExisting:      "Le compte n'existe pas",
 	Welcome:                 "Memtravel vous souhaite la bienvenue",
 	BlockedLogin:            "Votre compte est actuellement bloqué",
+	UsernameTaken:           "Ce nom d'utilisateur est déjà pris",
+	UsernameChangeLimit:     "Vous avez changé votre nom d'utilisateur trop de fois cette année",
 }
 
 var es = map[string]string{
@@ -86,6 +94,8 @@ var es = map[string]string{
 	AccountNotExisting:      "La cuenta no existe",
 	Welcome:                 "Memtravel te da la bienvenida",
 	BlockedLogin:            "Su cuenta está actualmente bloqueada",
+	UsernameTaken:           "Este nombre de usuario ya está en uso",
+	UsernameChangeLimit:     "Ha cambiado su nombre de usuario demasiadas veces este año",
 }
 
 // GetTranslation retrieves a translation for a specific language id
@@ -0,0 +1,209 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// MaxUploadSize is the biggest file accepted by the upload endpoints
+const MaxUploadSize = 10 << 20
+
+// MaxImagePixels is the biggest width times height accepted, a small compressed file can declare a huge image
+// that would take gigabytes of memory to decode
+const MaxImagePixels = 40_000_000
+
+const thumbnailQuality = 85
+
+// ThumbnailSize is the blueprint for a generated thumbnail, the longest side is scaled down to MaxSide
+type ThumbnailSize struct {
+	Name    string
+	MaxSide int
+}
+
+// ThumbnailSizes holds every thumbnail generated for an uploaded image
+var ThumbnailSizes = []ThumbnailSize{
+	{Name: "sm", MaxSide: 160},
+	{Name: "md", MaxSide: 640},
+	{Name: "lg", MaxSide: 1280},
+}
+
+var allowedContentTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+var (
+	errorUnsupportedContentType = errors.New("unsupported media content type")
+	errorImageTooLarge          = errors.New("image dimensions are too large")
+)
+
+// SaveImage sniffs and decodes the uploaded data, stores it under the given prefix together with its thumbnails
+// and returns the key of the original file and its content type
+func SaveImage(storage Storage, prefix string, data []byte) (string, string, error) {
+	contentType := http.DetectContentType(data)
+
+	extension, allowed := allowedContentTypes[contentType]
+	if !allowed {
+		return "", "", errorUnsupportedContentType
+	}
+
+	// the header is read first so the dimensions are checked before any pixel is allocated
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", "", err
+	}
+
+	if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > MaxImagePixels {
+		return "", "", errorImageTooLarge
+	}
+
+	// decoding makes sure the content is really an image and not only something with a matching header
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", "", err
+	}
+
+	key := path.Join(prefix, uuid.NewString()+extension)
+
+	err = storage.Save(key, bytes.NewReader(data))
+	if err != nil {
+		return "", "", err
+	}
+
+	// nothing of a half saved image is kept, the original and the thumbnails already written are removed
+	err = saveThumbnails(storage, key, img)
+	if err != nil {
+		return "", "", errors.Join(err, DeleteImage(storage, key))
+	}
+
+	return key, contentType, nil
+}
+
+// saveThumbnails stores every thumbnail size of the image of the key
+func saveThumbnails(storage Storage, key string, img image.Image) error {
+	// the image is flattened once for every size, a full size copy per thumbnail takes too much memory
+	flat := flatten(img)
+
+	for _, size := range ThumbnailSizes {
+		var thumbnail bytes.Buffer
+
+		err := jpeg.Encode(&thumbnail, scale(flat, size.MaxSide), &jpeg.Options{Quality: thumbnailQuality})
+		if err != nil {
+			return err
+		}
+
+		err = storage.Save(ThumbnailKey(key, size.Name), &thumbnail)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteImage removes an image stored by SaveImage and all its thumbnails
+func DeleteImage(storage Storage, key string) error {
+	for _, size := range ThumbnailSizes {
+		err := storage.Delete(ThumbnailKey(key, size.Name))
+		if err != nil {
+			return err
+		}
+	}
+
+	return storage.Delete(key)
+}
+
+// ThumbnailKey returns the key of the thumbnail with the given size name for an image key
+func ThumbnailKey(key string, size string) string {
+	return strings.TrimSuffix(key, path.Ext(key)) + "_" + size + ".jpg"
+}
+
+// ContentType returns the content type of a stored key based on its extension
+func ContentType(key string) string {
+	extension := path.Ext(key)
+	for contentType, allowedExtension := range allowedContentTypes {
+		if allowedExtension == extension {
+			return contentType
+		}
+	}
+
+	return "application/octet-stream"
+}
+
+// Thumbnail scales the image down so its longest side is at most maxSide, images are never scaled up
+func Thumbnail(src image.Image, maxSide int) image.Image {
+	return scale(flatten(src), maxSide)
+}
+
+// flatten copies the image onto white so transparent images do not turn black once encoded as jpeg
+func flatten(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+
+	flat := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flat, flat.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), src, bounds.Min, draw.Over)
+
+	return flat
+}
+
+// scale shrinks a flattened image so its longest side is at most maxSide. Every destination pixel is the
+// average of the source pixels it covers, which keeps thumbnails smooth
+func scale(flat *image.RGBA, maxSide int) image.Image {
+	width, height := flat.Bounds().Dx(), flat.Bounds().Dy()
+
+	if width <= maxSide && height <= maxSide {
+		return flat
+	}
+
+	dstWidth, dstHeight := maxSide, maxSide
+	if width > height {
+		dstHeight = max(1, height*maxSide/width)
+	} else {
+		dstWidth = max(1, width*maxSide/height)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+
+	for y := 0; y < dstHeight; y++ {
+		srcY0 := y * height / dstHeight
+		srcY1 := max(srcY0+1, (y+1)*height/dstHeight)
+
+		for x := 0; x < dstWidth; x++ {
+			srcX0 := x * width / dstWidth
+			srcX1 := max(srcX0+1, (x+1)*width/dstWidth)
+
+			var r, g, b, a, count int
+			for sy := srcY0; sy < srcY1; sy++ {
+				offset := flat.PixOffset(srcX0, sy)
+				for sx := srcX0; sx < srcX1; sx++ {
+					r += int(flat.Pix[offset])
+					g += int(flat.Pix[offset+1])
+					b += int(flat.Pix[offset+2])
+					a += int(flat.Pix[offset+3])
+					offset += 4
+					count++
+				}
+			}
+
+			offset := dst.PixOffset(x, y)
+			dst.Pix[offset] = uint8(r / count)
+			dst.Pix[offset+1] = uint8(g / count)
+			dst.Pix[offset+2] = uint8(b / count)
+			dst.Pix[offset+3] = uint8(a / count)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,167 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignURL_VerifiesOwnSignature(t *testing.T) {
+	secret := []byte("secret")
+	key := "trips/1/photo.jpg"
+
+	signed := SignURL(key, time.Now().Add(time.Minute), secret)
+
+	_, query, found := strings.Cut(signed, "?")
+	if !found {
+		t.Fatalf("Expected signed url to have a query: %s", signed)
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !VerifySignature(key, values.Get("exp"), values.Get("sig"), secret) {
+		t.Errorf("Expected signature to be valid")
+	}
+
+	if VerifySignature("trips/2/photo.jpg", values.Get("exp"), values.Get("sig"), secret) {
+		t.Errorf("Expected signature to be invalid for a different key")
+	}
+
+	if VerifySignature(key, values.Get("exp"), values.Get("sig"), []byte("other")) {
+		t.Errorf("Expected signature to be invalid for a different secret")
+	}
+}
+
+func TestSignURL_ExpiredSignatureIsInvalid(t *testing.T) {
+	secret := []byte("secret")
+	key := "trips/1/photo.jpg"
+
+	signed := SignURL(key, time.Now().Add(-time.Minute), secret)
+	_, query, _ := strings.Cut(signed, "?")
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if VerifySignature(key, values.Get("exp"), values.Get("sig"), secret) {
+		t.Errorf("Expected expired signature to be invalid")
+	}
+}
+
+func TestThumbnail_KeepsAspectRatio(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 400, 200))
+
+	bounds := Thumbnail(src, 100).Bounds()
+	if bounds.Dx() != 100 || bounds.Dy() != 50 {
+		t.Errorf("Expected 100x50 thumbnail, got %dx%d", bounds.Dx(), bounds.Dy())
+	}
+
+	bounds = Thumbnail(src, 1000).Bounds()
+	if bounds.Dx() != 400 || bounds.Dy() != 200 {
+		t.Errorf("Expected image not to be scaled up, got %dx%d", bounds.Dx(), bounds.Dy())
+	}
+}
+
+func TestSaveImage_StoresOriginalAndThumbnails(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	src.Set(0, 0, color.Black)
+
+	var data bytes.Buffer
+	err = png.Encode(&data, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, contentType, err := SaveImage(storage, "avatars", data.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if contentType != "image/png" || !strings.HasPrefix(key, "avatars/") {
+		t.Errorf("Unexpected key %s with content type %s", key, contentType)
+	}
+
+	for _, size := range ThumbnailSizes {
+		file, err := storage.Open(ThumbnailKey(key, size.Name))
+		if err != nil {
+			t.Fatalf("Expected %s thumbnail to exist: %s", size.Name, err)
+		}
+		file.Close()
+	}
+
+	err = DeleteImage(storage, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = storage.Open(key)
+	if err == nil {
+		t.Errorf("Expected original to be deleted")
+	}
+}
+
+func TestSaveImage_RejectsNonImages(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = SaveImage(storage, "avatars", []byte("<html><body>not an image</body></html>"))
+	if err == nil {
+		t.Errorf("Expected html upload to be rejected")
+	}
+}
+
+func TestSaveImage_RejectsHugeDimensions(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data bytes.Buffer
+	err = png.Encode(&data, image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rewrite the IHDR chunk so the file declares a 50000x50000 image
+	header := data.Bytes()
+	binary.BigEndian.PutUint32(header[16:20], 50000)
+	binary.BigEndian.PutUint32(header[20:24], 50000)
+	binary.BigEndian.PutUint32(header[29:33], crc32.ChecksumIEEE(header[12:29]))
+
+	_, _, err = SaveImage(storage, "avatars", header)
+	if err != errorImageTooLarge {
+		t.Errorf("Expected huge image to be rejected, got %v", err)
+	}
+}
+
+func TestLocalStorage_RejectsPathTraversal(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"../secret", "/etc/passwd", "trips/../../secret", "trips//photo.jpg"} {
+		err = storage.Save(key, io.NopCloser(strings.NewReader("data")))
+		if err == nil {
+			t.Errorf("Expected key %q to be rejected", key)
+		}
+	}
+}
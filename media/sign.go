@@ -0,0 +1,58 @@
+package media
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// URLPrefix is the path every media file is served from
+const URLPrefix = "/media/"
+
+// MinSecretLength is the shortest secret accepted to sign urls, anyone can forge the signatures of a short one
+const MinSecretLength = 32
+
+// SignURL creates a url for a private media key that is valid until the expiry time
+func SignURL(key string, expires time.Time, secret []byte) string {
+	expiry := strconv.FormatInt(expires.Unix(), 10)
+
+	query := url.Values{}
+	query.Set("exp", expiry)
+	query.Set("sig", signature(key, expiry, secret))
+
+	return URLPrefix + key + "?" + query.Encode()
+}
+
+// VerifySignature checks that the signature was created by SignURL for the key and that it has not expired
+func VerifySignature(key string, expiry string, sig string, secret []byte) bool {
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	if time.Now().Unix() > expiresAt {
+		return false
+	}
+
+	expected, err := hex.DecodeString(signature(key, expiry, secret))
+	if err != nil {
+		return false
+	}
+
+	received, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal(expected, received)
+}
+
+func signature(key string, expiry string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(key + "\n" + expiry))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,115 @@
+package media
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var errorInvalidKey = errors.New("invalid media key")
+
+// Storage is the blueprint for any backend that keeps uploaded media,
+// keys are slash separated paths such as "trips/12/photo.jpg"
+type Storage interface {
+	Save(key string, content io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// LocalStorage keeps media in a directory of the local filesystem
+type LocalStorage struct {
+	root string
+}
+
+// NewLocalStorage creates a local storage rooted at the given directory, creating it if needed
+func NewLocalStorage(root string) (*LocalStorage, error) {
+	absoluteRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(absoluteRoot, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalStorage{
+		root: absoluteRoot,
+	}, nil
+}
+
+// Save writes the content into the given key, replacing any existing file
+func (storage *LocalStorage) Save(key string, content io.Reader) error {
+	path, err := storage.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+
+	// write into a temporary file first so readers never see a partial file
+	file, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(file, content)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+
+	err = file.Close()
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+// Open opens the file stored in the given key
+func (storage *LocalStorage) Open(key string) (io.ReadCloser, error) {
+	path, err := storage.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(path)
+}
+
+// Delete removes the file stored in the given key, missing files are not an error
+func (storage *LocalStorage) Delete(key string) error {
+	path, err := storage.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// path converts a key into a filesystem path making sure it cannot escape the storage root
+func (storage *LocalStorage) path(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return "", errorInvalidKey
+	}
+
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", errorInvalidKey
+		}
+	}
+
+	return filepath.Join(storage.root, filepath.FromSlash(key)), nil
+}
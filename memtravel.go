@@ -5,11 +5,16 @@ import (
 	"html/template"
 	"log"
 	"net/http"
+	"os"
 	"time"
 
+	"memtravel/badges"
 	"memtravel/configs"
 	"memtravel/db"
+	"memtravel/emissions"
+	"memtravel/geo"
 	"memtravel/handlers"
+	"memtravel/media"
 	"memtravel/middleware"
 	"memtravel/ratelimiter"
 )
@@ -31,8 +36,49 @@ func main() {
 	defer database.Close()
 	defer ratelimiter.ShutdownLimiter()
 
-	// create a new handler which has database and templates available
-	handler := handlers.NewHandler(database, templates)
+	if len(configs.Envs.MediaSecret) < media.MinSecretLength {
+		log.Fatalf("MEDIA_SECRET must be at least %d characters long", media.MinSecretLength)
+	}
+
+	// media uploads are kept on the local filesystem
+	storage, err := media.NewLocalStorage(configs.Envs.MediaRoot)
+	if err != nil {
+		log.Fatalf("could not open media storage: %s", err)
+	}
+
+	// country borders used to find the countries crossed by imported tracks and drawn on exported maps,
+	// the bundled ones are used unless a file is configured
+	boundaries, err := geo.DefaultBoundaries()
+	if configs.Envs.Boundaries != "" {
+		boundaries, err = loadBoundaries(configs.Envs.Boundaries)
+	}
+
+	if err != nil {
+		log.Fatalf("could not load country boundaries: %s", err)
+	}
+
+	if boundaries == nil {
+		log.Println("country boundaries are not available, imports are disabled")
+	}
+
+	// emission factors per transport mode used for the carbon estimates of trips
+	factors := emissions.Default
+
+	if configs.Envs.Emissions != "" {
+		factors, err = loadEmissions(configs.Envs.Emissions)
+		if err != nil {
+			log.Fatalf("could not load emission factors: %s", err)
+		}
+	}
+
+	// a duplicate badge id or a rule that can never be earned must stop the deploy instead of hiding badges
+	err = badges.Validate(badges.Definitions)
+	if err != nil {
+		log.Fatalf("invalid badge definitions: %s", err)
+	}
+
+	// create a new handler which has database, templates, media storage, country boundaries and emission factors available
+	handler := handlers.NewHandler(database, templates, storage, boundaries, factors)
 
 	// create the middlewares we need
 	authMiddleware := middleware.CreateStack(middleware.BaseMiddleware, middleware.AuthMiddleware)
@@ -44,18 +90,33 @@ func main() {
 	http.HandleFunc("POST /account/password/change", authMiddleware(handler.PasswordChangeHandler))
 	http.HandleFunc("POST /account/close", authMiddleware(handler.CloseAccountHandler))
 	http.HandleFunc("POST /account/privacystatus", authMiddleware(handler.PrivacyStatusHandler))
+	http.HandleFunc("POST /account/tripvisibility", authMiddleware(handler.TripVisibilityHandler))
 	http.HandleFunc("POST /account/update/country", authMiddleware(handler.UpdateCountryHandler))
 	http.HandleFunc("GET /account/activate/{code}", middleware.BaseMiddleware(handler.ActivateAccountHandler))
+	http.HandleFunc("POST /account/calendar/regenerate", authMiddleware(handler.RegenerateCalendarHandler))
+	http.HandleFunc("POST /account/calendar/revoke", authMiddleware(handler.RevokeCalendarHandler))
 
 	// friends deals with anything that is part of the social interaction
 	http.HandleFunc("POST /friends/request/{type}", authMiddleware(handler.FriendRequestHandler))
 	http.HandleFunc("POST /friends/remove", authMiddleware(handler.RemoveFriendHandler))
 	http.HandleFunc("GET /friends/all", authMiddleware(handler.GetFriendsHandler))
+	http.HandleFunc("GET /friends/requests/incoming", authMiddleware(handler.GetIncomingFriendRequestsHandler))
+	http.HandleFunc("GET /friends/requests/outgoing", authMiddleware(handler.GetOutgoingFriendRequestsHandler))
+	http.HandleFunc("GET /friends/requests/count", authMiddleware(handler.CountFriendRequestsHandler))
+	http.HandleFunc("GET /friends/close", authMiddleware(handler.GetCloseFriendsHandler))
+	http.HandleFunc("POST /friends/close/add", authMiddleware(handler.AddCloseFriendHandler))
+	http.HandleFunc("POST /friends/close/remove", authMiddleware(handler.RemoveCloseFriendHandler))
 
 	// users deals with any search/user account view
 	http.HandleFunc("GET /users/search", authMiddleware(handler.SearchUsersHandler))
+	http.HandleFunc("GET /users/username/available", middleware.BaseMiddleware(handler.UsernameAvailableHandler))
 	http.HandleFunc("GET /users/account/view", authMiddleware(handler.GetUserHandler))
 	http.HandleFunc("POST /users/account/edit", authMiddleware(handler.UserEditHandler))
+	http.HandleFunc("GET /users/{id}/map", authMiddleware(handler.GetWorldMapHandler))
+	http.HandleFunc("GET /users/{id}/map/export", authMiddleware(handler.ExportMapHandler))
+	http.HandleFunc("GET /users/{id}/bucketlist", authMiddleware(handler.GetBucketListHandler))
+	http.HandleFunc("GET /users/{id}/badges", authMiddleware(handler.GetUserBadgesHandler))
+	http.HandleFunc("GET /users/{id}/pinned", authMiddleware(handler.GetPinnedHandler))
 
 	// trips deals with anything that is related with the trips
 	http.HandleFunc("POST /trips/add", authMiddleware(handler.AddTripHandler))
@@ -63,17 +124,89 @@ func main() {
 	http.HandleFunc("GET /trips/previous", authMiddleware(handler.GetPreviousTripsHandler))
 	http.HandleFunc("POST /trips/edit/{id}", authMiddleware(handler.EditTripHandler))
 	http.HandleFunc("POST /trips/remove/{id}", authMiddleware(handler.RemoveTripHandler))
-	http.HandleFunc("GET /trips/stats", authMiddleware(handler.RemoveTripHandler))
+	http.HandleFunc("GET /trips/stats", authMiddleware(handler.GetTripStatsHandler))
+	http.HandleFunc("GET /trips/calendar", authMiddleware(handler.TripsCalendarHandler))
+	http.HandleFunc("POST /trips/import", authMiddleware(handler.ImportTripHandler))
+	http.HandleFunc("GET /trips/drafts", authMiddleware(handler.GetTripDraftsHandler))
+	http.HandleFunc("POST /trips/drafts/confirm/{id}", authMiddleware(handler.ConfirmTripDraftHandler))
+	http.HandleFunc("POST /trips/drafts/remove/{id}", authMiddleware(handler.RemoveTripDraftHandler))
+
+	// legs deals with the ordered stops inside a trip
+	http.HandleFunc("GET /trips/{id}/legs", authMiddleware(handler.GetLegsHandler))
+	http.HandleFunc("POST /trips/{id}/legs/add", authMiddleware(handler.AddLegHandler))
+	http.HandleFunc("POST /trips/{id}/legs/reorder", authMiddleware(handler.ReorderLegsHandler))
+	http.HandleFunc("POST /trips/{id}/legs/remove/{lgid}", authMiddleware(handler.RemoveLegHandler))
+
+	// journal deals with the dated entries written during a trip
+	http.HandleFunc("GET /trips/{id}/journal", authMiddleware(handler.GetJournalHandler))
+	http.HandleFunc("POST /trips/{id}/journal/add", authMiddleware(handler.AddJournalEntryHandler))
+	http.HandleFunc("POST /trips/{id}/journal/edit/{eid}", authMiddleware(handler.EditJournalEntryHandler))
+	http.HandleFunc("POST /trips/{id}/journal/remove/{eid}", authMiddleware(handler.RemoveJournalEntryHandler))
+
+	// media deals with uploads and serving of trip photos, covers and avatars
+	http.HandleFunc("POST /media/avatar", authMiddleware(handler.UploadAvatarHandler))
+	http.HandleFunc("GET /media/{key...}", middleware.BaseMiddleware(handler.ServeMediaHandler))
+	http.HandleFunc("GET /trips/{id}/media", authMiddleware(handler.GetTripMediaHandler))
+	http.HandleFunc("POST /trips/{id}/media/add", authMiddleware(handler.AddTripMediaHandler))
+	http.HandleFunc("POST /trips/{id}/media/cover", authMiddleware(handler.UploadTripCoverHandler))
+	http.HandleFunc("POST /trips/{id}/media/remove/{mid}", authMiddleware(handler.RemoveTripMediaHandler))
 
 	// pinned
 	http.HandleFunc("POST /pinned/add/{tpid}", authMiddleware(handler.AddPinnedHandler))
 	http.HandleFunc("POST /pinned/remove/{tpid}", authMiddleware(handler.RemovePinnedHandler))
 
+	// bucketlist deals with the destinations a user wants to visit
+	http.HandleFunc("POST /bucketlist/add", authMiddleware(handler.AddBucketEntryHandler))
+	http.HandleFunc("POST /bucketlist/reorder", authMiddleware(handler.ReorderBucketListHandler))
+	http.HandleFunc("POST /bucketlist/remove/{id}", authMiddleware(handler.RemoveBucketEntryHandler))
+
 	// ratings
 	http.HandleFunc("POST /ratings/add", authMiddleware(handler.AddRatingHandler))
+	http.HandleFunc("GET /ratings/countries", middleware.BaseMiddleware(handler.GetCountriesRatingsHandler))
+	http.HandleFunc("GET /ratings/country/{cid}", middleware.BaseMiddleware(handler.GetCountryRatingsHandler))
 
 	// organise
-	http.HandleFunc("POST /organise/request", authMiddleware(handler.FriendRequestHandler))
+	http.HandleFunc("POST /organise/create", authMiddleware(handler.CreatePlanHandler))
+	http.HandleFunc("POST /organise/request", authMiddleware(handler.OrganiseRequestHandler))
+	http.HandleFunc("GET /organise/all", authMiddleware(handler.GetPlansHandler))
+	http.HandleFunc("GET /organise/{id}", authMiddleware(handler.GetPlanHandler))
+	http.HandleFunc("POST /organise/{id}/respond/{response}", authMiddleware(handler.RespondPlanHandler))
+	http.HandleFunc("POST /organise/{id}/destinations/add", authMiddleware(handler.AddPlanDestinationHandler))
+	http.HandleFunc("POST /organise/{id}/dates/add", authMiddleware(handler.AddPlanDateHandler))
+	http.HandleFunc("POST /organise/{id}/vote/{kind}", authMiddleware(handler.VotePlanHandler))
+	http.HandleFunc("POST /organise/{id}/settle", authMiddleware(handler.SettlePlanHandler))
+
+	// members and expenses are shared between the owner of a trip and the friends added to it
+	http.HandleFunc("GET /trips/{id}/members", authMiddleware(handler.GetTripMembersHandler))
+	http.HandleFunc("POST /trips/{id}/members/add", authMiddleware(handler.AddTripMemberHandler))
+	http.HandleFunc("POST /trips/{id}/members/remove", authMiddleware(handler.RemoveTripMemberHandler))
+	http.HandleFunc("GET /trips/{id}/expenses", authMiddleware(handler.GetExpensesHandler))
+	http.HandleFunc("GET /trips/{id}/expenses/balances", authMiddleware(handler.GetExpenseBalancesHandler))
+	http.HandleFunc("POST /trips/{id}/expenses/add", authMiddleware(handler.AddExpenseHandler))
+	http.HandleFunc("POST /trips/{id}/expenses/remove/{exid}", authMiddleware(handler.RemoveExpenseHandler))
+	http.HandleFunc("GET /trips/{id}/budget", authMiddleware(handler.GetTripBudgetHandler))
+
+	// calendar subscriptions are authenticated by the secret token in the url instead of the Authorization header
+	http.HandleFunc("GET /calendar/{token}", middleware.BaseMiddleware(handler.CalendarFeedHandler))
+
+	// share links show a read only page of a trip to anyone that has the link
+	http.HandleFunc("GET /trips/{id}/shares", authMiddleware(handler.GetTripSharesHandler))
+	http.HandleFunc("POST /trips/{id}/shares/add", authMiddleware(handler.AddTripShareHandler))
+	http.HandleFunc("POST /trips/{id}/shares/remove/{sid}", authMiddleware(handler.RemoveTripShareHandler))
+	http.HandleFunc("GET /share/{token}", middleware.BaseMiddleware(handler.SharedTripHandler))
+	http.HandleFunc("GET /share/{token}/media/{key...}", middleware.BaseMiddleware(handler.SharedMediaHandler))
+
+	// checklists of a trip, templates are reusable checklists kept per user
+	http.HandleFunc("GET /trips/{id}/checklists", authMiddleware(handler.GetChecklistsHandler))
+	http.HandleFunc("POST /trips/{id}/checklists/add", authMiddleware(handler.AddChecklistHandler))
+	http.HandleFunc("POST /trips/{id}/checklists/remove/{clid}", authMiddleware(handler.RemoveChecklistHandler))
+	http.HandleFunc("POST /trips/{id}/checklists/{clid}/items/add", authMiddleware(handler.AddChecklistItemHandler))
+	http.HandleFunc("POST /trips/{id}/checklists/{clid}/items/remove/{ciid}", authMiddleware(handler.RemoveChecklistItemHandler))
+	http.HandleFunc("POST /trips/{id}/checklists/{clid}/items/check/{ciid}", authMiddleware(handler.CheckChecklistItemHandler))
+	http.HandleFunc("POST /trips/{id}/checklists/{clid}/items/assign/{ciid}", authMiddleware(handler.AssignChecklistItemHandler))
+	http.HandleFunc("GET /checklists/templates", authMiddleware(handler.GetChecklistTemplatesHandler))
+	http.HandleFunc("POST /checklists/templates/add", authMiddleware(handler.AddChecklistTemplateHandler))
+	http.HandleFunc("POST /checklists/templates/remove/{ctid}", authMiddleware(handler.RemoveChecklistTemplateHandler))
 
 	// country
 	http.HandleFunc("GET /country/all", middleware.BaseMiddleware(handler.GetAllCountries))
@@ -85,7 +218,7 @@ func main() {
 
 	server := &http.Server{
 		Addr:         configs.Envs.Port,
-		ReadTimeout:  5 * time.Second,
+		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 5 * time.Second,
 	}
 
@@ -95,3 +228,26 @@ func main() {
 		panic(err.Error())
 	}
 }
+
+// loadBoundaries reads the country boundaries GeoJSON file
+func loadBoundaries(path string) (*geo.Boundaries, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return geo.LoadBoundaries(file)
+}
+
+func loadEmissions(path string) (emissions.Factors, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return emissions.Load(file)
+}